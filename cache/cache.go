@@ -11,27 +11,72 @@
 package cache
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/replicate/go/lock"
 	"github.com/replicate/go/logging"
+	"github.com/replicate/go/must"
 	"github.com/replicate/go/telemetry"
 )
 
 var (
 	logger = logging.New("cache")
 	tracer = telemetry.Tracer("go", "cache")
+	meter  = telemetry.Meter("go", "cache")
+
+	fetchDuration = must.Get(meter.Float64Histogram(
+		"cache.fetch.duration",
+		metric.WithDescription("Duration of calls to cache fetchers"),
+		metric.WithUnit("s"),
+	))
+
+	// These counters are only recorded for caches configured WithMetrics.
+	hits = must.Get(meter.Int64Counter(
+		"cache.hits",
+		metric.WithDescription("Number of reads served fresh data from the cache"),
+	))
+	softMisses = must.Get(meter.Int64Counter(
+		"cache.soft_misses",
+		metric.WithDescription("Number of reads served stale data from the cache"),
+	))
+	hardMisses = must.Get(meter.Int64Counter(
+		"cache.hard_misses",
+		metric.WithDescription("Number of reads which found no data in the cache"),
+	))
+	negativeHits = must.Get(meter.Int64Counter(
+		"cache.negative_hits",
+		metric.WithDescription("Number of reads which found cached non-existence"),
+	))
+	fillErrors = must.Get(meter.Int64Counter(
+		"cache.fill_errors",
+		metric.WithDescription("Number of hard misses which failed to fill the cache"),
+	))
+	refreshLockAcquired = must.Get(meter.Int64Counter(
+		"cache.refresh_lock_acquired",
+		metric.WithDescription("Number of soft misses which acquired the refresh lock and refreshed the cache"),
+	))
+	refreshLockContended = must.Get(meter.Int64Counter(
+		"cache.refresh_lock_contended",
+		metric.WithDescription("Number of soft misses which skipped refreshing the cache because the refresh lock was held"),
+	))
 
 	// internal error indicating a hard cache miss
 	errCacheMiss = errors.New("value not in cache")
@@ -46,45 +91,93 @@ var (
 	// the cache to the zero value of the cache type T. This is disallowed to
 	// prevent accidentally poisoning the cache with invalid data.
 	ErrDisallowedCacheValue = errors.New("nil and zero values are not permitted")
+
+	// ErrInvalidTTL is returned by SetWithTTL if the passed durations are
+	// invalid.
+	ErrInvalidTTL = errors.New("invalid cache TTL")
+
+	// ErrFetcherPanicked is returned by Get if the fetcher panicked while
+	// filling the cache on a hard miss.
+	ErrFetcherPanicked = errors.New("cache fetcher panicked")
+
+	// ErrInvalidName is returned by NewCacheE if the cache name is empty or
+	// contains characters which would produce ambiguous or malformed keys.
+	ErrInvalidName = errors.New("invalid cache name")
+)
+
+const (
+	// defaultWriteLockTTL is the default for WithWriteLockTTL.
+	defaultWriteLockTTL = 5 * time.Second
+
+	// fillTimeout bounds the fetch and write shared by coalesced hard misses.
+	fillTimeout = 30 * time.Second
+
+	// minJitteredStaleGap is the minimum by which TTL jitter keeps the stale
+	// duration longer than the fresh duration. See jitter.
+	minJitteredStaleGap = time.Second
 )
 
 type Fetcher[T any] func(ctx context.Context, key string) (T, error)
 
 type Cache[T any] struct {
-	name    string
-	opts    cacheOptions
-	clients []redis.Cmdable
-	locker  lock.Locker
+	name     string
+	opts     cacheOptions
+	clients  []redis.Cmdable
+	locker   lock.Locker
+	counters [numOutcomes]atomic.Int64
+	fills    singleflight.Group
+	local    *localTier
 }
 
+// NewCache creates a cache backed by the given Redis client. It panics if name
+// is invalid: see NewCacheE for details.
 func NewCache[T any](
 	client redis.Cmdable,
 	name string,
 	fresh, stale time.Duration,
 	options ...Option,
 ) *Cache[T] {
-	c := Cache[T]{
-		name:    name,
-		clients: []redis.Cmdable{client},
-		locker:  lock.Locker{Clients: []redis.Cmdable{client}},
-	}
-
-	c.opts.Fresh = fresh
-	c.opts.Stale = stale
-
-	for _, o := range options {
-		o.apply(&c.opts)
-	}
+	return must.Get(NewCacheE[T](client, name, fresh, stale, options...))
+}
 
-	return &c
+// NewCacheE behaves like NewCache, but returns an error wrapping
+// ErrInvalidName instead of panicking if name is invalid. Names must be
+// non-empty and must not contain whitespace, colons (which separate the
+// components of cache keys), "@" (which separates the schema version), or
+// Redis glob metacharacters. The same characters are disallowed in the version
+// passed to WithSchemaVersion.
+func NewCacheE[T any](
+	client redis.Cmdable,
+	name string,
+	fresh, stale time.Duration,
+	options ...Option,
+) (*Cache[T], error) {
+	return newCache[T]([]redis.Cmdable{client}, name, fresh, stale, options...)
 }
 
+// NewCacheMultipleBackends creates a cache backed by all of the given Redis
+// clients. Like NewCache, it panics if name is invalid.
 func NewCacheMultipleBackends[T any](
 	clients []redis.Cmdable,
 	name string,
 	fresh, stale time.Duration,
 	options ...Option,
 ) *Cache[T] {
+	return must.Get(newCache[T](clients, name, fresh, stale, options...))
+}
+
+// newCache validates name and builds a cache backed by clients, applying
+// options and filling in defaults for any left unset.
+func newCache[T any](
+	clients []redis.Cmdable,
+	name string,
+	fresh, stale time.Duration,
+	options ...Option,
+) (*Cache[T], error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
 	c := Cache[T]{
 		name:    name,
 		clients: clients,
@@ -97,8 +190,23 @@ func NewCacheMultipleBackends[T any](
 	for _, o := range options {
 		o.apply(&c.opts)
 	}
+	if err := validateKeyComponent(c.opts.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("invalid schema version: %w", err)
+	}
+	if c.opts.Locker != nil {
+		c.locker = *c.opts.Locker
+	}
+	if c.opts.Codec == nil {
+		c.opts.Codec = JSONCodec{}
+	}
+	if c.opts.WriteLockTTL == 0 {
+		c.opts.WriteLockTTL = defaultWriteLockTTL
+	}
+	if c.opts.LocalSize > 0 {
+		c.local = newLocalTier(c.opts.LocalSize, c.opts.LocalTTL)
+	}
 
-	return &c
+	return &c, nil
 }
 
 func (c *Cache[T]) Prepare(ctx context.Context) error {
@@ -113,7 +221,17 @@ func (c *Cache[T]) Prepare(ctx context.Context) error {
 // Get fetches an item with the given key from cache. In the event of a cache
 // miss or an error communicating with the cache, it will fall back to fetching
 // the item from source using the passed fetcher.
+//
+// If ctx was returned by WithBypass, the cache is not read, and the item is
+// fetched and written to the cache as on a hard miss.
 func (c *Cache[T]) Get(ctx context.Context, key string, fetcher Fetcher[T]) (value T, err error) {
+	return c.get(ctx, key, fetcher, 0)
+}
+
+// get implements Get. If readTimeout is positive, reading from the cache is
+// bounded by it, so that if the read times out the fallback to a direct fetch
+// has the rest of ctx's deadline to run.
+func (c *Cache[T]) get(ctx context.Context, key string, fetcher Fetcher[T], readTimeout time.Duration) (value T, err error) {
 	log := logger.With(logging.GetFields(ctx)...).Sugar()
 
 	if c == nil {
@@ -121,7 +239,20 @@ func (c *Cache[T]) Get(ctx context.Context, key string, fetcher Fetcher[T]) (val
 		return fetcher(ctx, key)
 	}
 
-	value, err = c.fetch(ctx, key, fetcher)
+	if bypassed(ctx) {
+		// Don't join an in-flight fill: it may have fetched the value the caller
+		// knows to be stale.
+		return c.fillOnce(ctx, key, fetcher)
+	}
+
+	readCtx := ctx
+	if readTimeout > 0 {
+		var cancel context.CancelFunc
+		readCtx, cancel = context.WithTimeout(ctx, readTimeout)
+		defer cancel()
+	}
+
+	value, err = c.fetch(readCtx, key, fetcher)
 	switch {
 	case err == nil:
 		return value, err
@@ -142,6 +273,27 @@ func (c *Cache[T]) Get(ctx context.Context, key string, fetcher Fetcher[T]) (val
 	}
 }
 
+// GetWithTimeout behaves like Get, but bounds the entire operation (reading
+// from the cache and, if necessary, filling it) by the given timeout. Reading
+// from the cache is bounded by half the timeout, so that if the read times out
+// Get's usual fallback to a direct fetch has the remainder of the timeout to
+// complete. When the deadline is exceeded the returned error wraps
+// context.DeadlineExceeded.
+//
+// The bound relies on the fetcher respecting cancellation of its context, and
+// on the Redis client respecting context deadlines (see the
+// ContextTimeoutEnabled option in go-redis).
+func (c *Cache[T]) GetWithTimeout(ctx context.Context, key string, fetcher Fetcher[T], timeout time.Duration) (value T, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	value, err = c.get(ctx, key, fetcher, timeout/2)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+	return value, err
+}
+
 // Set updates the value stored in a given key with a provided object. This is
 // not always needed (as usually values are fetched using the provided
 // Fetcher[T]) but can be useful in some cases.
@@ -149,28 +301,376 @@ func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
 	return c.set(ctx, key, value)
 }
 
+// SetWithTTL behaves like Set, but stores the value with the given fresh and
+// stale durations rather than those the cache was configured with. The
+// overrides apply only to this write: if the value is later refreshed using a
+// fetcher, it will be stored with the cache's usual durations.
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, fresh, stale time.Duration) error {
+	if fresh <= 0 || stale < fresh {
+		return fmt.Errorf("%w: fresh (%s) must be positive and no longer than stale (%s)", ErrInvalidTTL, fresh, stale)
+	}
+	return c.setWithTTL(ctx, key, value, fresh, stale)
+}
+
+// SetOptions overrides the durations with which an individual write stores a
+// value or non-existence in the cache. Zero fields fall back to the durations
+// the cache was configured with.
+type SetOptions struct {
+	// Fresh and Stale override the fresh and stale durations of a value stored
+	// by SetWithOptions. Fresh must be no longer than Stale.
+	Fresh time.Duration
+	Stale time.Duration
+
+	// Negative overrides the duration for which SetDoesNotExist caches the
+	// non-existence of a key.
+	Negative time.Duration
+}
+
+// SetWithOptions behaves like Set, but stores the value with the fresh and
+// stale durations given in opts, if any. As with SetWithTTL, the overrides
+// apply only to this write.
+func (c *Cache[T]) SetWithOptions(ctx context.Context, key string, value T, opts SetOptions) error {
+	fresh := cmp.Or(opts.Fresh, c.opts.Fresh)
+	stale := cmp.Or(opts.Stale, c.opts.Stale)
+	if fresh <= 0 || stale < fresh {
+		return fmt.Errorf("%w: fresh (%s) must be positive and no longer than stale (%s)", ErrInvalidTTL, fresh, stale)
+	}
+	return c.setWithTTL(ctx, key, value, fresh, stale)
+}
+
+// SetDoesNotExist removes any value stored for key and records that it does
+// not exist, so that Get returns ErrDoesNotExist without calling the fetcher
+// until the record expires. The record is kept for opts.Negative if set, or
+// otherwise for the duration configured WithNegativeCaching. If neither is
+// set, the value is removed but non-existence is not cached.
+func (c *Cache[T]) SetDoesNotExist(ctx context.Context, key string, opts SetOptions) error {
+	if opts.Negative < 0 {
+		return fmt.Errorf("%w: negative (%s) must not be negative", ErrInvalidTTL, opts.Negative)
+	}
+	negative := cmp.Or(opts.Negative, c.opts.Negative)
+	if negative == 0 {
+		return c.remove(ctx, key)
+	}
+	return c.setNegativeWithTTL(ctx, key, negative)
+}
+
+// GetOrSet returns the fresh value stored in the cache for key if there is
+// one. Otherwise, it stores the passed value in the cache and returns it. The
+// check and update are made while holding the same lock as background
+// refreshes, so concurrent callers will agree on the stored value.
+//
+// If the non-existence of key has been cached, GetOrSet returns
+// ErrDoesNotExist. If the cache is unavailable, the passed value is returned.
+func (c *Cache[T]) GetOrSet(ctx context.Context, key string, value T) (T, error) {
+	log := logger.With(logging.GetFields(ctx)...).Sugar()
+
+	if c == nil {
+		log.Warnf("cache not configured: returning value directly")
+		return value, nil
+	}
+
+	if !c.opts.AllowZeroValue && reflect.ValueOf(value).IsZero() {
+		return value, ErrDisallowedCacheValue
+	}
+
+	ctx, span := tracer.Start(
+		ctx,
+		"cache.get_or_set",
+		trace.WithAttributes(c.spanAttributes(key)...),
+	)
+	defer span.End()
+
+	existing, ok, err := c.getFresh(ctx, key)
+	if ok || err != nil {
+		return existing, err
+	}
+
+	keys := c.keysFor(key)
+
+	lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	l, err := c.locker.Acquire(lockCtx, keys.lock, c.opts.Stale)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		log.Warnw("cache lock failed: returning value without caching", "error", err)
+		return value, nil
+	}
+	defer func() {
+		err := l.Release(ctx)
+		if err != nil {
+			recordError(ctx, fmt.Errorf("error releasing update lock: %w", err))
+		}
+	}()
+
+	// Someone else may have stored a value while we were waiting for the lock.
+	existing, ok, err = c.getFresh(ctx, key)
+	if ok || err != nil {
+		return existing, err
+	}
+
+	if err := c.set(ctx, key, value); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		log.Warnw("cache fill failed", "error", err)
+	}
+	return value, nil
+}
+
+// getFresh returns the fresh value for key, if there is one. It returns
+// ErrDoesNotExist if non-existence has been cached, but otherwise swallows
+// errors communicating with the cache, treating them as a miss.
+func (c *Cache[T]) getFresh(ctx context.Context, key string) (value T, ok bool, err error) {
+	data, fresh, err := c.lookup(ctx, key)
+	switch {
+	case errors.Is(err, ErrDoesNotExist):
+		return value, false, err
+	case errors.Is(err, errCacheMiss):
+		return value, false, nil
+	case err != nil:
+		recordError(ctx, fmt.Errorf("error reading from cache: %w", err))
+		return value, false, nil
+	case !fresh:
+		return value, false, nil
+	}
+
+	value, err = c.decode(data)
+	if err != nil {
+		recordError(ctx, fmt.Errorf("error decoding cached value: %w", err))
+		return value, false, nil
+	}
+	return value, true, nil
+}
+
+// GetRaw returns the bytes stored in the primary Redis for key, without
+// decoding them. It is intended for debugging serialization problems. The
+// returned bool is false if no value is stored, or if the non-existence of key
+// has been cached. GetRaw does not distinguish between fresh and stale data.
+func (c *Cache[T]) GetRaw(ctx context.Context, key string) ([]byte, bool, error) {
+	if c == nil {
+		logger.With(logging.GetFields(ctx)...).Sugar().Warnf("cache not configured: no raw value")
+		return nil, false, nil
+	}
+
+	keys := c.keysFor(key)
+	result, err := c.clients[0].MGet(ctx, keys.data, keys.negative).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(result) != 2 {
+		return nil, false, fmt.Errorf("incorrect number of values from redis: got %d, expected 2", len(result))
+	}
+	if result[0] == nil || result[1] != nil {
+		return nil, false, nil
+	}
+
+	data, ok := result[0].(string)
+	if !ok {
+		return nil, false, fmt.Errorf("unable to interpret redis value as string: %v", result[0])
+	}
+	return []byte(data), true, nil
+}
+
+// Refill fetches a fresh value for key using the passed fetcher and overwrites
+// any value stored in the cache. Unlike removing the entry, readers continue to
+// be served the existing value until the new one is written, so they never
+// experience a miss. This is intended for event-driven invalidation, for
+// example when the underlying data is known to have changed.
+//
+// Refill takes the same lock as background refreshes, so it will wait for any
+// in-progress refresh to complete. If the fetcher returns ErrDoesNotExist, the
+// existing value is removed and (if negative caching is enabled) the
+// non-existence is cached.
+func (c *Cache[T]) Refill(ctx context.Context, key string, fetcher Fetcher[T]) (value T, err error) {
+	log := logger.With(logging.GetFields(ctx)...).Sugar()
+
+	if c == nil {
+		log.Warnf("cache not configured: fetching data directly")
+		return fetcher(ctx, key)
+	}
+
+	ctx, span := tracer.Start(
+		ctx,
+		"cache.refill",
+		trace.WithAttributes(c.spanAttributes(key)...),
+	)
+	defer span.End()
+
+	keys := c.keysFor(key)
+
+	lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	l, err := c.locker.Acquire(lockCtx, keys.lock, c.opts.Stale)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return value, fmt.Errorf("error acquiring cache lock: %w", err)
+	}
+	defer func() {
+		err := l.Release(ctx)
+		if err != nil {
+			recordError(ctx, fmt.Errorf("error releasing update lock: %w", err))
+		}
+	}()
+
+	return c.refillLocked(ctx, key, fetcher)
+}
+
+// Refresh synchronously fetches a fresh value for key using the passed fetcher
+// and writes it to the cache, in the same way as a background refresh on a soft
+// miss. It is intended for proactively keeping hot keys fresh ahead of expiry.
+//
+// Unlike Refill, Refresh does not wait for the refresh lock: if another refresh
+// of key is in progress it returns an error wrapping lock.ErrLockNotAcquired.
+// If the fetcher returns ErrDoesNotExist, the existing value is removed and (if
+// negative caching is enabled) the non-existence is cached.
+func (c *Cache[T]) Refresh(ctx context.Context, key string, fetcher Fetcher[T]) error {
+	if c == nil {
+		logger.With(logging.GetFields(ctx)...).Sugar().Warnf("cache not configured: refresh is a no-op")
+		return nil
+	}
+
+	ctx, span := tracer.Start(
+		ctx,
+		"cache.refresh",
+		trace.WithAttributes(c.spanAttributes(key)...),
+	)
+	defer span.End()
+
+	keys := c.keysFor(key)
+
+	l, err := c.locker.TryAcquire(ctx, keys.lock, c.opts.Stale)
+	if err != nil {
+		if !errors.Is(err, lock.ErrLockNotAcquired) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return fmt.Errorf("error acquiring cache lock: %w", err)
+	}
+	defer func() {
+		err := l.Release(ctx)
+		if err != nil {
+			recordError(ctx, fmt.Errorf("error releasing update lock: %w", err))
+		}
+	}()
+
+	_, err = c.refillLocked(ctx, key, fetcher)
+	return err
+}
+
+// refillLocked fetches a fresh value for key and overwrites the cached value
+// (or caches its non-existence). The caller must hold the refresh lock.
+func (c *Cache[T]) refillLocked(ctx context.Context, key string, fetcher Fetcher[T]) (value T, err error) {
+	span := trace.SpanFromContext(ctx)
+
+	value, err = c.timedFetch(ctx, key, fetcher)
+	if errors.Is(err, ErrDoesNotExist) {
+		if err := c.remove(ctx, key); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return value, err
+		}
+		if err := c.setNegative(ctx, key); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return value, err
+		}
+		return value, err
+	} else if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return value, err
+	}
+
+	if err := c.set(ctx, key, value); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return value, err
+	}
+
+	return value, nil
+}
+
 // fetch attempts to retrieve the value from cache. In the event of a hard cache
 // miss it returns errCacheMiss, and for a soft miss it starts a goroutine to
 // refill the cache.
 func (c *Cache[T]) fetch(ctx context.Context, key string, fetcher Fetcher[T]) (value T, err error) {
+	if data, ok := c.local.get(key); ok {
+		c.count(ctx, outcomeHit)
+		return c.decode(data)
+	}
+
+	data, fresh, err := c.lookup(ctx, key)
+	switch {
+	case errors.Is(err, ErrDoesNotExist):
+		c.count(ctx, outcomeNegativeHit)
+		return value, err
+	case errors.Is(err, errCacheMiss):
+		c.count(ctx, outcomeHardMiss)
+		return value, err
+	case err != nil:
+		return value, err
+	case !fresh:
+		// soft cache miss: serve stale data and kick off a refresh
+		c.count(ctx, outcomeSoftMiss)
+		c.serveStale(ctx, key, fetcher)
+	default:
+		c.count(ctx, outcomeHit)
+	}
+
+	value, err = c.decode(data)
+	if err == nil && fresh {
+		c.local.add(key, data.(string))
+	}
+	return value, err
+}
+
+// count records an outcome in the cache's in-process counters (see Stats) and,
+// if the cache is configured WithMetrics, the corresponding metric.
+func (c *Cache[T]) count(ctx context.Context, o outcome) {
+	c.counters[o].Add(1)
+	if !c.opts.Metrics {
+		return
+	}
+	if counter := o.metric(); counter != nil {
+		counter.Add(ctx, 1, metric.WithAttributes(attribute.String("cache.name", c.name)))
+	}
+}
+
+// serveStale optionally logs that stale data is being served for key (a soft
+// cache miss) and kicks off a refresh. The soft miss itself is counted by the
+// caller.
+func (c *Cache[T]) serveStale(ctx context.Context, key string, fetcher Fetcher[T]) {
+	if c.opts.LogStaleServes {
+		logger.With(logging.GetFields(ctx)...).Sugar().Infow("serving stale data from cache", "cache.name", c.name, "cache.key", key)
+	}
+	c.refresh(ctx, key, fetcher)
+}
+
+// lookup retrieves the raw cached data for key, and whether it is fresh. In the
+// event of a hard cache miss it returns errCacheMiss, and if non-existence has
+// been cached it returns ErrDoesNotExist.
+func (c *Cache[T]) lookup(ctx context.Context, key string) (data any, fresh bool, err error) {
 	keys := c.keysFor(key)
 
-	var fresh, data, negative any
-	// return the first positive result
-	for _, client := range c.clients {
-		result, err := client.MGet(ctx, keys.fresh, keys.data, keys.negative).Result()
-		if err != nil {
-			return value, err
+	var results []mgetResult
+	if c.opts.HedgedReads && len(c.clients) > 1 {
+		results = c.mgetHedged(ctx, keys)
+	} else {
+		results = make([]mgetResult, 0, len(c.clients))
+		for _, client := range c.clients {
+			r := c.mget(ctx, client, keys)
+			results = append(results, r)
+			if r.err != nil || r.hit() {
+				break
+			}
 		}
-		if len(result) != 3 {
-			return value, fmt.Errorf("incorrect number of values from redis: got %d, expected 3", len(result))
+	}
+
+	var freshVal, negative any
+	// return the first positive result
+	for _, r := range results {
+		if r.err != nil {
+			return nil, false, r.err
 		}
 
-		fresh = result[0]
-		data = result[1]
-		negative = result[2]
+		freshVal = r.values[0]
+		data = r.values[1]
+		negative = r.values[2]
 
-		if fresh != nil && data != nil {
+		if r.hit() {
 			// cache hit
 			break
 		}
@@ -178,25 +678,73 @@ func (c *Cache[T]) fetch(ctx context.Context, key string, fetcher Fetcher[T]) (v
 
 	if negative != nil {
 		// cached non-existence
-		return value, ErrDoesNotExist
+		return nil, false, ErrDoesNotExist
 	}
 
 	if data == nil {
 		// hard cache miss
-		return value, errCacheMiss
+		return nil, false, errCacheMiss
+	}
+
+	return data, freshVal != nil, nil
+}
+
+type mgetResult struct {
+	values []any // fresh, data, negative
+	err    error
+}
+
+func (r mgetResult) hit() bool {
+	return r.err == nil && r.values[0] != nil && r.values[1] != nil
+}
+
+func (c *Cache[T]) mget(ctx context.Context, client redis.Cmdable, keys keys) mgetResult {
+	result, err := client.MGet(ctx, keys.fresh, keys.data, keys.negative).Result()
+	if err != nil {
+		return mgetResult{err: err}
+	}
+	if len(result) != 3 {
+		return mgetResult{err: fmt.Errorf("incorrect number of values from redis: got %d, expected 3", len(result))}
 	}
+	return mgetResult{values: result}
+}
+
+// mgetHedged queries all the clients concurrently. If any client returns a
+// cache hit, the outstanding queries are cancelled and only the hit is
+// returned. Otherwise, all the results are returned in client order.
+func (c *Cache[T]) mgetHedged(ctx context.Context, keys keys) []mgetResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	if fresh == nil {
-		// soft cache miss: kick off a refresh
-		c.refresh(ctx, key, fetcher)
+	type indexed struct {
+		i int
+		r mgetResult
 	}
+	ch := make(chan indexed, len(c.clients))
+	for i, client := range c.clients {
+		go func() {
+			ch <- indexed{i, c.mget(ctx, client, keys)}
+		}()
+	}
+
+	results := make([]mgetResult, len(c.clients))
+	for range c.clients {
+		res := <-ch
+		if res.r.hit() {
+			return []mgetResult{res.r}
+		}
+		results[res.i] = res.r
+	}
+	return results
+}
 
+func (c *Cache[T]) decode(data any) (value T, err error) {
 	valueStr, ok := data.(string)
 	if !ok {
 		return value, fmt.Errorf("unable to interpret redis value as string: %v", data)
 	}
 
-	err = json.Unmarshal([]byte(valueStr), &value)
+	err = c.opts.Codec.Unmarshal([]byte(valueStr), &value)
 	if err != nil {
 		return value, err
 	}
@@ -206,7 +754,77 @@ func (c *Cache[T]) fetch(ctx context.Context, key string, fetcher Fetcher[T]) (v
 
 // fill attempts to fetch a value from the upstream (using the passed fetcher)
 // and update the cache. It is called in the event of a hard cache miss.
+//
+// Concurrent calls to fill for the same key within this process are coalesced,
+// so that only one of them calls the fetcher and the others share its result.
+// Unless the cache is configured WithBlockingFirstFill, the distributed lock
+// only guards refreshes on soft misses, so without this a burst of requests for
+// a cold key would all hit the upstream.
+//
+// The shared fill is not tied to the cancellation of any one caller, so that
+// one cancelled request doesn't fail all the others waiting on the same key.
+// Instead it is bounded by fillTimeout. A panic in the fetcher is returned to
+// all callers as an error wrapping ErrFetcherPanicked, as it would otherwise be
+// re-raised on a goroutine where it can't be recovered.
 func (c *Cache[T]) fill(ctx context.Context, key string, fetcher Fetcher[T]) (value T, err error) {
+	ch := c.fills.DoChan(c.keysFor(key).data, func() (v any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%w: %v", ErrFetcherPanicked, r)
+			}
+		}()
+
+		fillCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fillTimeout)
+		defer cancel()
+
+		if c.opts.BlockingFirstFill {
+			return c.fillLocked(fillCtx, key, fetcher)
+		}
+		return c.fillOnce(fillCtx, key, fetcher)
+	})
+
+	select {
+	case <-ctx.Done():
+		return value, ctx.Err()
+	case res := <-ch:
+		value, _ = res.Val.(T)
+		return value, res.Err
+	}
+}
+
+// fillLocked fills the cache as fillOnce, but holds the refresh lock while
+// doing so. If another process filled the cache while we were waiting for the
+// lock, its value is returned without calling the fetcher.
+func (c *Cache[T]) fillLocked(ctx context.Context, key string, fetcher Fetcher[T]) (value T, err error) {
+	log := logger.With(logging.GetFields(ctx)...).Sugar()
+
+	lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	l, err := c.locker.Acquire(lockCtx, c.keysFor(key).lock, c.opts.Stale)
+	if err != nil {
+		log.Warnw("cache lock failed: filling without lock", "error", err)
+		return c.fillOnce(ctx, key, fetcher)
+	}
+	defer func() {
+		err := l.Release(ctx)
+		if err != nil {
+			recordError(ctx, fmt.Errorf("error releasing update lock: %w", err))
+		}
+	}()
+
+	// Someone else may have filled the cache while we were waiting for the lock.
+	data, _, err := c.lookup(ctx, key)
+	switch {
+	case err == nil:
+		return c.decode(data)
+	case errors.Is(err, ErrDoesNotExist):
+		return value, err
+	}
+
+	return c.fillOnce(ctx, key, fetcher)
+}
+
+func (c *Cache[T]) fillOnce(ctx context.Context, key string, fetcher Fetcher[T]) (value T, err error) {
 	log := logger.With(logging.GetFields(ctx)...).Sugar()
 
 	ctx, span := tracer.Start(
@@ -217,13 +835,15 @@ func (c *Cache[T]) fill(ctx context.Context, key string, fetcher Fetcher[T]) (va
 	)
 	defer span.End()
 
-	value, err = fetcher(ctx, key)
+	value, err = c.timedFetch(ctx, key, fetcher)
 	if errors.Is(err, ErrDoesNotExist) {
 		if err := c.setNegative(ctx, key); err != nil {
+			c.count(ctx, outcomeFillError)
 			return value, err
 		}
 		return value, err
 	} else if err != nil {
+		c.count(ctx, outcomeFillError)
 		span.SetStatus(codes.Error, err.Error())
 		return value, err
 	}
@@ -233,30 +853,38 @@ func (c *Cache[T]) fill(ctx context.Context, key string, fetcher Fetcher[T]) (va
 		// Errors encountered while filling the cache are not returned to the
 		// caller: we don't want a cache availability problem to be exposed if the
 		// value was already successfully fetched.
+		c.count(ctx, outcomeFillError)
 		span.SetStatus(codes.Error, err.Error())
 		log.Warnw("cache fill failed", "error", err)
+	} else {
+		c.count(ctx, outcomeFill)
 	}
 
 	return value, nil
 }
 
 func (c *Cache[T]) set(ctx context.Context, key string, value T) error {
-	// We don't accept the zero value of T into the cache. This could easily be a
-	// bug and we don't want to take the risk of poisoning the cache.
-	if reflect.ValueOf(value).IsZero() {
+	return c.setWithTTL(ctx, key, value, c.opts.Fresh, c.opts.Stale)
+}
+
+func (c *Cache[T]) setWithTTL(ctx context.Context, key string, value T, fresh, stale time.Duration) error {
+	// We don't accept the zero value of T into the cache (unless explicitly
+	// configured to). This could easily be a bug and we don't want to take the
+	// risk of poisoning the cache.
+	if !c.opts.AllowZeroValue && reflect.ValueOf(value).IsZero() {
 		return ErrDisallowedCacheValue
 	}
 
 	keys := c.keysFor(key)
 
-	data, err := json.Marshal(value)
+	data, err := c.opts.Codec.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.opts.WriteLockTTL)
 	defer cancel()
-	l, err := c.acquireIfMultipleRedises(ctx, keys.lockMultiple, 5*time.Second)
+	l, err := c.acquireIfMultipleRedises(ctx, keys.lockMultiple, c.opts.WriteLockTTL)
 	if err != nil {
 		return err
 	}
@@ -267,33 +895,143 @@ func (c *Cache[T]) set(ctx context.Context, key string, value T) error {
 		}
 	}()
 
+	fresh, stale = c.jitter(fresh, stale)
+
 	errs := []error{}
 	for _, client := range c.clients {
 		pipe := client.TxPipeline()
+		queueSet(ctx, pipe, keys, data, fresh, stale)
+		_, err = pipe.Exec(ctx)
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		c.local.remove(key)
+		return err
+	}
+	c.local.add(key, string(data))
+	return nil
+}
 
-		// Remove any explicit nonexistence sentinel
-		pipe.Del(ctx, keys.negative)
-		// Update cached value
-		pipe.Set(ctx, keys.data, string(data), c.opts.Stale)
-		// Set freshness sentinel
-		pipe.Set(ctx, keys.fresh, 1, c.opts.Fresh)
+// jitter applies the configured TTL jitter (if any) to the fresh and stale
+// durations. If stale was longer than fresh, it remains longer by at least the
+// smaller of the original gap and minJitteredStaleGap, so that jitter never
+// closes the window in which stale data is served while it is refreshed.
+func (c *Cache[T]) jitter(fresh, stale time.Duration) (time.Duration, time.Duration) {
+	if c.opts.TTLJitter == 0 {
+		return fresh, stale
+	}
+	gap := min(max(stale-fresh, 0), minJitteredStaleGap)
+	perturb := func(d time.Duration) time.Duration {
+		return d + time.Duration((2*rand.Float64()-1)*c.opts.TTLJitter*float64(d))
+	}
+	fresh, stale = perturb(fresh), perturb(stale)
+	return fresh, max(stale, fresh+gap)
+}
 
-		_, err = pipe.Exec(ctx)
+// queueSet adds the commands to store data for keys to pipe.
+func queueSet(ctx context.Context, pipe redis.Pipeliner, keys keys, data []byte, fresh, stale time.Duration) {
+	// Remove any explicit nonexistence sentinel
+	pipe.Del(ctx, keys.negative)
+	// Update cached value
+	pipe.Set(ctx, keys.data, string(data), stale)
+	// Set freshness sentinel
+	pipe.Set(ctx, keys.fresh, 1, fresh)
+}
+
+// Warm stores all the passed entries in the cache, as Set, but writes them in a
+// single transaction per backend. This is intended for priming the cache at
+// startup.
+//
+// Entries which can't be stored (e.g. zero values, which are rejected with
+// ErrDisallowedCacheValue) are skipped, and their errors are returned joined
+// with any error writing the remaining entries.
+func (c *Cache[T]) Warm(ctx context.Context, entries map[string]T) error {
+	if c == nil {
+		logger.With(logging.GetFields(ctx)...).Sugar().Warnf("cache not configured: warm is a no-op")
+		return nil
+	}
+
+	type encoded struct {
+		keys         keys
+		data         []byte
+		fresh, stale time.Duration
+	}
+	batch := make([]encoded, 0, len(entries))
+	lockKeys := make([]string, 0, len(entries))
+	errs := []error{}
+	for key, value := range entries {
+		if !c.opts.AllowZeroValue && reflect.ValueOf(value).IsZero() {
+			errs = append(errs, fmt.Errorf("key %s: %w", key, ErrDisallowedCacheValue))
+			continue
+		}
+		data, err := c.opts.Codec.Marshal(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %s: %w", key, err))
+			continue
+		}
+		keys := c.keysFor(key)
+		fresh, stale := c.jitter(c.opts.Fresh, c.opts.Stale)
+		batch = append(batch, encoded{keys: keys, data: data, fresh: fresh, stale: stale})
+		lockKeys = append(lockKeys, keys.lockMultiple)
+	}
+	if len(batch) == 0 {
+		return errors.Join(errs...)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.WriteLockTTL)
+	defer cancel()
+	var l lock.Lock = nullLock
+	if len(c.clients) > 1 {
+		var err error
+		l, err = c.locker.AcquireMulti(ctx, lockKeys, c.opts.WriteLockTTL)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+	}
+	defer func() {
+		err := l.Release(ctx)
+		if err != nil {
+			recordError(ctx, fmt.Errorf("error releasing update lock: %w", err))
+		}
+	}()
+
+	for _, client := range c.clients {
+		pipe := client.TxPipeline()
+		for _, e := range batch {
+			queueSet(ctx, pipe, e.keys, e.data, e.fresh, e.stale)
+		}
+		_, err := pipe.Exec(ctx)
 		errs = append(errs, err)
 	}
+	for key := range entries {
+		c.local.remove(key)
+	}
 	return errors.Join(errs...)
 }
 
 func (c *Cache[T]) setNegative(ctx context.Context, key string) error {
+	return c.setNegativeWithTTL(ctx, key, c.opts.Negative)
+}
+
+func (c *Cache[T]) setNegativeWithTTL(ctx context.Context, key string, negative time.Duration) error {
+	c.local.remove(key)
+
 	// If negative caching is not enabled, this is a no-op.
-	if c.opts.Negative == 0 {
+	if negative == 0 {
 		return nil
 	}
 
 	keys := c.keysFor(key)
 
+	pipe := c.clients[0].TxPipeline()
+	// Remove any cached value, so that it can't be served if the nonexistence
+	// sentinel expires before it does
+	pipe.Del(ctx, keys.fresh, keys.data)
 	// Record non-existence sentinel in the cache
-	return c.clients[0].Set(ctx, keys.negative, 1, c.opts.Negative).Err()
+	pipe.Set(ctx, keys.negative, 1, negative)
+
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
 type _nullLock struct{}
@@ -321,6 +1059,7 @@ func (c *Cache[T]) refresh(ctx context.Context, key string, fetcher Fetcher[T])
 	// serve stale values.
 	l, err := c.locker.TryAcquire(ctx, keys.lock, c.opts.Stale)
 	if errors.Is(err, lock.ErrLockNotAcquired) {
+		c.count(ctx, outcomeRefreshLockContended)
 		return
 	} else if err != nil {
 		// We record other errors but don't do anything to interrupt serving from
@@ -329,6 +1068,8 @@ func (c *Cache[T]) refresh(ctx context.Context, key string, fetcher Fetcher[T])
 		return
 	}
 
+	c.count(ctx, outcomeRefreshLockAcquired)
+
 	// Create a new root span which links to the span which triggered the
 	// background refresh.
 	//
@@ -355,9 +1096,14 @@ func (c *Cache[T]) refreshInner(ctx context.Context, key string, fetcher Fetcher
 		}
 	}()
 
-	value, err := fetcher(ctx, key)
+	value, err := c.timedFetch(ctx, key, fetcher)
 	if err != nil {
 		recordError(ctx, fmt.Errorf("error fetching fresh value for cache: %w", err))
+		if c.opts.ServeStaleOnError {
+			if err := c.extendStale(ctx, key); err != nil {
+				recordError(ctx, fmt.Errorf("error extending stale cache value: %w", err))
+			}
+		}
 		return
 	}
 	err = c.set(ctx, key, value)
@@ -367,6 +1113,51 @@ func (c *Cache[T]) refreshInner(ctx context.Context, key string, fetcher Fetcher
 	}
 }
 
+// remove deletes the cached data for key from all backends.
+func (c *Cache[T]) remove(ctx context.Context, key string) error {
+	c.local.remove(key)
+
+	keys := c.keysFor(key)
+
+	errs := []error{}
+	for _, client := range c.clients {
+		errs = append(errs, client.Del(ctx, keys.fresh, keys.data).Err())
+	}
+	return errors.Join(errs...)
+}
+
+// timedFetch calls fetcher, recording how long it took on the current span (and
+// optionally as a metric), so that upstream latency can be distinguished from
+// time spent talking to Redis.
+func (c *Cache[T]) timedFetch(ctx context.Context, key string, fetcher Fetcher[T]) (T, error) {
+	start := time.Now()
+	value, err := fetcher(ctx, key)
+	c.recordFetchDuration(ctx, time.Since(start))
+	return value, err
+}
+
+func (c *Cache[T]) recordFetchDuration(ctx context.Context, d time.Duration) {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Float64("cache.fetch_duration_ms", float64(d)/float64(time.Millisecond)),
+	)
+	if c.opts.FetchDurationMetric {
+		fetchDuration.Record(ctx, d.Seconds(), metric.WithAttributes(attribute.String("cache.name", c.name)))
+	}
+}
+
+// extendStale resets the expiry of the cached data for key to the stale
+// duration, without marking it as fresh, so that it continues to be served
+// while further refreshes are attempted.
+func (c *Cache[T]) extendStale(ctx context.Context, key string) error {
+	keys := c.keysFor(key)
+
+	errs := []error{}
+	for _, client := range c.clients {
+		errs = append(errs, client.Expire(ctx, keys.data, c.opts.Stale).Err())
+	}
+	return errors.Join(errs...)
+}
+
 type keys struct {
 	data         string
 	fresh        string
@@ -376,13 +1167,37 @@ type keys struct {
 }
 
 func (c *Cache[T]) keysFor(key string) keys {
+	ns := c.name
+	if c.opts.SchemaVersion != "" {
+		ns = c.name + "@" + c.opts.SchemaVersion
+	}
 	return keys{
-		data:         fmt.Sprintf("cache:data:%s:%s", c.name, key),
-		fresh:        fmt.Sprintf("cache:fresh:%s:%s", c.name, key),
-		lock:         fmt.Sprintf("cache:lock:%s:%s", c.name, key),
-		lockMultiple: fmt.Sprintf("cache:lock-multiple:%s:%s", c.name, key),
-		negative:     fmt.Sprintf("cache:negative:%s:%s", c.name, key),
+		data:         fmt.Sprintf("cache:data:%s:%s", ns, key),
+		fresh:        fmt.Sprintf("cache:fresh:%s:%s", ns, key),
+		lock:         fmt.Sprintf("cache:lock:%s:%s", ns, key),
+		lockMultiple: fmt.Sprintf("cache:lock-multiple:%s:%s", ns, key),
+		negative:     fmt.Sprintf("cache:negative:%s:%s", ns, key),
+	}
+}
+
+func validateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name must not be empty", ErrInvalidName)
+	}
+	return validateKeyComponent(name)
+}
+
+// validateKeyComponent checks that s can be spliced into cache keys and SCAN
+// patterns without corrupting their layout.
+func validateKeyComponent(s string) error {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(":@*?[]\\", r)
+	})
+	if i >= 0 {
+		r, _ := utf8.DecodeRuneInString(s[i:])
+		return fmt.Errorf("%w: %q contains disallowed character %q", ErrInvalidName, s, r)
 	}
+	return nil
 }
 
 func (c *Cache[T]) spanAttributes(key string) []attribute.KeyValue {
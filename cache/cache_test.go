@@ -1,16 +1,31 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"encoding/gob"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redismock/v9"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/replicate/go/lock"
+	"github.com/replicate/go/test"
 )
 
 type testObj struct {
@@ -30,6 +45,14 @@ type mockWrapper struct {
 	fresh    time.Duration
 	stale    time.Duration
 	negative time.Duration
+	lockTTL  time.Duration
+}
+
+func (m mockWrapper) writeLockTTL() time.Duration {
+	if m.lockTTL == 0 {
+		return defaultWriteLockTTL
+	}
+	return m.lockTTL
 }
 
 func (m mockWrapper) ExpectCacheFetchEmpty(key string) {
@@ -60,6 +83,18 @@ func (m mockWrapper) ExpectCacheFetchFresh(key string, value any) {
 	).SetVal([]any{1, string(data), nil})
 }
 
+func (m mockWrapper) ExpectCacheFetchStale(key string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	m.ExpectMGet(
+		"cache:fresh:"+m.name+":"+key,
+		"cache:data:"+m.name+":"+key,
+		"cache:negative:"+m.name+":"+key,
+	).SetVal([]any{nil, string(data), nil})
+}
+
 func (m mockWrapper) ExpectCacheFetchNegative(key string) {
 	m.ExpectMGet(
 		"cache:fresh:"+m.name+":"+key,
@@ -86,7 +121,7 @@ func (m mockWrapper) ExpectCacheFillWithLock(key string, value any) {
 		panic(err)
 	}
 	lockMultiple := "cache:lock-multiple:" + m.name + ":" + key
-	m.Regexp().ExpectSetNX(lockMultiple, `.*`, 5*time.Second).SetVal(true)
+	m.Regexp().ExpectSetNX(lockMultiple, `.*`, m.writeLockTTL()).SetVal(true)
 	m.ExpectTxPipeline()
 	m.ExpectDel("cache:negative:" + m.name + ":" + key).SetVal(0)
 	m.ExpectSet("cache:data:"+m.name+":"+key, string(data), m.stale).SetVal("OK")
@@ -97,14 +132,17 @@ func (m mockWrapper) ExpectCacheFillWithLock(key string, value any) {
 
 func (m mockWrapper) ExpectCacheFillWithLockErr(key string, err error) {
 	lockMultiple := "cache:lock-multiple:" + m.name + ":" + key
-	m.Regexp().ExpectSetNX(lockMultiple, `.*`, 5*time.Second).SetVal(true)
+	m.Regexp().ExpectSetNX(lockMultiple, `.*`, m.writeLockTTL()).SetVal(true)
 	m.ExpectTxPipeline()
 	m.ExpectDel("cache:negative:" + m.name + ":" + key).SetErr(err)
 	m.Regexp().ExpectEvalSha(`.*`, []string{lockMultiple}, `.*`).SetVal(int64(1))
 }
 
 func (m mockWrapper) ExpectCacheFillNegative(key string) {
+	m.ExpectTxPipeline()
+	m.ExpectDel("cache:fresh:"+m.name+":"+key, "cache:data:"+m.name+":"+key).SetVal(0)
 	m.ExpectSet("cache:negative:"+m.name+":"+key, 1, m.negative).SetVal("OK")
+	m.ExpectTxPipelineExec()
 }
 
 func TestCacheFetchesWhenNotInCache(t *testing.T) {
@@ -488,3 +526,1517 @@ func TestCacheSetZeroValueForbidden(t *testing.T) {
 	err := cache.Set(ctx, "elephant", value)
 	assert.ErrorIs(t, err, ErrDisallowedCacheValue)
 }
+
+func TestCacheServeStaleOnError(t *testing.T) {
+	ctx := test.Context(t)
+
+	fresh := 10 * time.Second
+	stale := 30 * time.Second
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", fresh, stale, WithServeStaleOnError())
+
+	obj := testObj{Value: "value_for:elephant"}
+	require.NoError(t, cache.Set(ctx, "elephant", obj))
+
+	// Upstream is down from now on.
+	var calls atomic.Int32
+	failingFetcher := func(context.Context, string) (testObj, error) {
+		calls.Add(1)
+		return testObj{}, errors.New("upstream unavailable")
+	}
+
+	for i := 1; i <= 3; i++ {
+		// The entry is now stale, with less than half of its remaining lifetime.
+		mr.FastForward(20 * time.Second)
+
+		v, err := cache.Get(ctx, "elephant", failingFetcher)
+		require.NoError(t, err)
+		assert.Equal(t, obj, v)
+
+		// Wait for the background refresh to fail and extend the stale entry.
+		require.Eventually(t, func() bool {
+			return calls.Load() == int32(i) && mr.TTL("cache:data:objects:elephant") == stale
+		}, time.Second, time.Millisecond)
+		require.Eventually(t, func() bool {
+			return !mr.Exists("cache:lock:objects:elephant")
+		}, time.Second, time.Millisecond)
+	}
+}
+
+func TestCacheWithoutServeStaleOnErrorExpires(t *testing.T) {
+	ctx := test.Context(t)
+
+	fresh := 10 * time.Second
+	stale := 30 * time.Second
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", fresh, stale)
+
+	obj := testObj{Value: "value_for:elephant"}
+	require.NoError(t, cache.Set(ctx, "elephant", obj))
+
+	var calls atomic.Int32
+	failingFetcher := func(context.Context, string) (testObj, error) {
+		calls.Add(1)
+		return testObj{}, errors.New("upstream unavailable")
+	}
+
+	mr.FastForward(20 * time.Second)
+
+	v, err := cache.Get(ctx, "elephant", failingFetcher)
+	require.NoError(t, err)
+	assert.Equal(t, obj, v)
+
+	require.Eventually(t, func() bool {
+		return calls.Load() == 1 && !mr.Exists("cache:lock:objects:elephant")
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 10*time.Second, mr.TTL("cache:data:objects:elephant"))
+
+	// Once the stale period is over, the upstream error is returned.
+	mr.FastForward(10 * time.Second)
+
+	_, err = cache.Get(ctx, "elephant", failingFetcher)
+	assert.ErrorContains(t, err, "upstream unavailable")
+}
+
+func TestCacheRefill(t *testing.T) {
+	ctx := test.Context(t)
+
+	fresh := 10 * time.Second
+	stale := 30 * time.Second
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", fresh, stale)
+
+	old := testObj{Value: "old_value_for:elephant"}
+	require.NoError(t, cache.Set(ctx, "elephant", old))
+
+	mustNotFetch := func(context.Context, string) (testObj, error) {
+		t.Error("unexpected fetch: cache should not have missed")
+		return testObj{}, errors.New("unexpected fetch")
+	}
+
+	calls := 0
+	updated := testObj{Value: "new_value_for:elephant"}
+	v, err := cache.Refill(ctx, "elephant", func(ctx context.Context, key string) (testObj, error) {
+		calls++
+
+		// While the refill is in progress, readers are still served the old value.
+		v, err := cache.Get(ctx, key, mustNotFetch)
+		require.NoError(t, err)
+		assert.Equal(t, old, v)
+
+		return updated, nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, updated, v)
+	assert.Equal(t, 1, calls)
+
+	v, err = cache.Get(ctx, "elephant", mustNotFetch)
+	require.NoError(t, err)
+	assert.Equal(t, updated, v)
+
+	assert.Equal(t, fresh, mr.TTL("cache:fresh:objects:elephant"))
+	assert.Equal(t, stale, mr.TTL("cache:data:objects:elephant"))
+	assert.False(t, mr.Exists("cache:lock:objects:elephant"))
+}
+
+func TestCacheRefillDoesNotExist(t *testing.T) {
+	ctx := test.Context(t)
+
+	fresh := 10 * time.Second
+	stale := 30 * time.Second
+	negative := 5 * time.Second
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", fresh, stale, WithNegativeCaching(negative))
+
+	require.NoError(t, cache.Set(ctx, "elephant", testObj{Value: "value_for:elephant"}))
+
+	_, err := cache.Refill(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		return testObj{}, ErrDoesNotExist
+	})
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+
+	assert.False(t, mr.Exists("cache:data:objects:elephant"))
+	assert.False(t, mr.Exists("cache:fresh:objects:elephant"))
+	assert.True(t, mr.Exists("cache:negative:objects:elephant"))
+
+	_, err = cache.Get(ctx, "elephant", fetchTestObj)
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+}
+
+func TestCacheRefillFetchError(t *testing.T) {
+	ctx := test.Context(t)
+
+	fresh := 10 * time.Second
+	stale := 30 * time.Second
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", fresh, stale)
+
+	old := testObj{Value: "value_for:elephant"}
+	require.NoError(t, cache.Set(ctx, "elephant", old))
+
+	_, err := cache.Refill(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		return testObj{}, errors.New("upstream unavailable")
+	})
+	assert.ErrorContains(t, err, "upstream unavailable")
+
+	// The existing value is untouched.
+	v, err := cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	assert.Equal(t, old, v)
+}
+
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	orig := tracer
+	tracer = tp.Tracer("test")
+	t.Cleanup(func() { tracer = orig })
+
+	return sr
+}
+
+func slowFetcher(d time.Duration) Fetcher[testObj] {
+	return func(ctx context.Context, key string) (testObj, error) {
+		time.Sleep(d)
+		return fetchTestObj(ctx, key)
+	}
+}
+
+func TestCacheRecordsFetchDuration(t *testing.T) {
+	ctx := test.Context(t)
+	sr := useSpanRecorder(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	_, err := cache.Get(ctx, "elephant", slowFetcher(50*time.Millisecond))
+	require.NoError(t, err)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+
+	var duration float64
+	var found bool
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == "cache.fetch_duration_ms" {
+			duration = kv.Value.AsFloat64()
+			found = true
+		}
+	}
+	require.True(t, found, "expected cache.fetch_duration_ms attribute on span")
+	assert.GreaterOrEqual(t, duration, 50.0)
+	assert.Less(t, duration, 500.0)
+}
+
+func TestCacheFetchDurationMetric(t *testing.T) {
+	ctx := test.Context(t)
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	orig := fetchDuration
+	h, err := mp.Meter("test").Float64Histogram("cache.fetch.duration")
+	require.NoError(t, err)
+	fetchDuration = h
+	t.Cleanup(func() { fetchDuration = orig })
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithFetchDurationMetric())
+
+	_, err = cache.Get(ctx, "elephant", slowFetcher(10*time.Millisecond))
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+
+	hist, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, hist.DataPoints, 1)
+
+	dp := hist.DataPoints[0]
+	assert.Equal(t, uint64(1), dp.Count)
+	assert.GreaterOrEqual(t, dp.Sum, 0.01)
+	name, ok := dp.Attributes.Value(attribute.Key("cache.name"))
+	require.True(t, ok)
+	assert.Equal(t, "objects", name.AsString())
+}
+
+func TestCacheFetchDurationMetricDisabledByDefault(t *testing.T) {
+	ctx := test.Context(t)
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	orig := fetchDuration
+	h, err := mp.Meter("test").Float64Histogram("cache.fetch.duration")
+	require.NoError(t, err)
+	fetchDuration = h
+	t.Cleanup(func() { fetchDuration = orig })
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	_, err = cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	assert.Empty(t, rm.ScopeMetrics)
+}
+
+// slowHook delays every Redis command by delay, or until the command's context
+// is done.
+type slowHook struct {
+	delay time.Duration
+}
+
+func (h slowHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h slowHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		select {
+		case <-time.After(h.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h slowHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+// contextFetcher waits for d or until the context is done, whichever is
+// sooner.
+func contextFetcher(d time.Duration, calls *atomic.Int32) Fetcher[testObj] {
+	return func(ctx context.Context, key string) (testObj, error) {
+		calls.Add(1)
+		select {
+		case <-time.After(d):
+			return fetchTestObj(ctx, key)
+		case <-ctx.Done():
+			return testObj{}, ctx.Err()
+		}
+	}
+}
+
+func TestCacheGetWithTimeout(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	var calls atomic.Int32
+	v, err := cache.GetWithTimeout(ctx, "elephant", contextFetcher(0, &calls), 200*time.Millisecond)
+
+	require.NoError(t, err)
+	assert.Equal(t, testObj{Value: "value_for:elephant"}, v)
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestCacheGetWithTimeoutSlowFetcher(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	var calls atomic.Int32
+	start := time.Now()
+	_, err := cache.GetWithTimeout(ctx, "elephant", contextFetcher(10*time.Second, &calls), 20*time.Millisecond)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestCacheGetWithTimeoutSlowRedis(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	rdb.AddHook(slowHook{delay: 10 * time.Second})
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	var calls atomic.Int32
+	start := time.Now()
+	_, err := cache.GetWithTimeout(ctx, "elephant", contextFetcher(10*time.Second, &calls), 20*time.Millisecond)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	// The cache read timed out, so we should have fallen back to a direct fetch.
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestCacheGetWithTimeoutSlowRedisFastFetcher(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	rdb.AddHook(slowHook{delay: 10 * time.Second})
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	var calls atomic.Int32
+	v, err := cache.GetWithTimeout(ctx, "elephant", contextFetcher(0, &calls), 100*time.Millisecond)
+
+	// The cache read timed out, leaving time for the direct fetch to succeed.
+	require.NoError(t, err)
+	assert.Equal(t, testObj{Value: "value_for:elephant"}, v)
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestCacheSetNegativeClearsData(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithNegativeCaching(5*time.Second))
+
+	require.NoError(t, cache.Set(ctx, "elephant", testObj{Value: "value_for:elephant"}))
+	require.True(t, mr.Exists("cache:data:objects:elephant"))
+	require.True(t, mr.Exists("cache:fresh:objects:elephant"))
+
+	require.NoError(t, cache.setNegative(ctx, "elephant"))
+
+	assert.False(t, mr.Exists("cache:data:objects:elephant"))
+	assert.False(t, mr.Exists("cache:fresh:objects:elephant"))
+	assert.True(t, mr.Exists("cache:negative:objects:elephant"))
+
+	// Once the negative entry expires, the old value must not be served.
+	mr.FastForward(6 * time.Second)
+
+	v, err := cache.Get(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		return testObj{Value: "new_value_for:elephant"}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testObj{Value: "new_value_for:elephant"}, v)
+}
+
+func TestCacheSetZeroValueWithAllowZeroValue(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[int](rdb, "counts", 10*time.Second, 30*time.Second, WithAllowZeroValue())
+
+	require.NoError(t, cache.Set(ctx, "elephant", 0))
+
+	v, err := cache.Get(ctx, "elephant", func(context.Context, string) (int, error) {
+		t.Error("unexpected fetch: cache should not have missed")
+		return 42, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, v)
+}
+
+func TestCacheSoftMissSkipsRefreshWhenLockHeld(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+
+	// Someone else is already refreshing this key.
+	lockClient, lockMock := redismock.NewClientMock()
+	lockMock.Regexp().ExpectSetNX("cache:lock:objects:elephant", `.*`, 30*time.Second).SetVal(false)
+
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithLocker(lock.Locker{Clients: []redis.Cmdable{lockClient}}))
+
+	old := testObj{Value: "old_value_for:elephant"}
+	require.NoError(t, cache.Set(ctx, "elephant", old))
+	mr.FastForward(11 * time.Second)
+
+	var calls atomic.Int32
+	v, err := cache.Get(ctx, "elephant", func(ctx context.Context, key string) (testObj, error) {
+		calls.Add(1)
+		return fetchTestObj(ctx, key)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, old, v)
+	assert.NoError(t, lockMock.ExpectationsWereMet())
+
+	assert.Never(t, func() bool { return calls.Load() > 0 }, 50*time.Millisecond, 5*time.Millisecond)
+}
+
+func TestCacheStaleServeMetric(t *testing.T) {
+	ctx := test.Context(t)
+
+	reader := useOutcomeCounters(t)
+
+	fresh := 10 * time.Second
+	stale := 30 * time.Second
+
+	client, mock := redismock.NewClientMock()
+	cacheMock := mockWrapper{
+		ClientMock: mock,
+
+		name:  "objects",
+		fresh: fresh,
+		stale: stale,
+	}
+	cache := NewCache[testObj](client, "objects", fresh, stale, WithStaleServeLogging(), WithMetrics())
+
+	old := testObj{Value: "old_value_for:elephant"}
+	cacheMock.ExpectCacheFetchStale("elephant", old)
+	cacheMock.Regexp().ExpectSetNX("cache:lock:objects:elephant", `.*`, stale).SetVal(true)
+	cacheMock.ExpectCacheFill("elephant", testObj{Value: "value_for:elephant"})
+	cacheMock.Regexp().ExpectEvalSha(`.*`, []string{"cache:lock:objects:elephant"}, `.*`).SetVal(int64(1))
+
+	var calls atomic.Int32
+	v, err := cache.Get(ctx, "elephant", func(ctx context.Context, key string) (testObj, error) {
+		calls.Add(1)
+		return fetchTestObj(ctx, key)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, old, v)
+
+	// A refresh was triggered in the background
+	require.Eventually(t, func() bool {
+		return mock.ExpectationsWereMet() == nil
+	}, 500*time.Millisecond, 5*time.Millisecond)
+	assert.EqualValues(t, 1, calls.Load())
+
+	assert.Equal(t, map[string]int64{
+		"cache.soft_misses":           1,
+		"cache.refresh_lock_acquired": 1,
+	}, collectCounters(t, reader))
+}
+
+func TestCacheGetOrSet(t *testing.T) {
+	ctx := test.Context(t)
+
+	fresh := 10 * time.Second
+	stale := 30 * time.Second
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", fresh, stale)
+
+	mine := testObj{Value: "my_value_for:elephant"}
+	theirs := testObj{Value: "their_value_for:elephant"}
+
+	// Cache miss: the provided value is stored
+	v, err := cache.GetOrSet(ctx, "elephant", mine)
+	require.NoError(t, err)
+	assert.Equal(t, mine, v)
+	assert.Equal(t, fresh, mr.TTL("cache:fresh:objects:elephant"))
+	assert.Equal(t, stale, mr.TTL("cache:data:objects:elephant"))
+	assert.False(t, mr.Exists("cache:lock:objects:elephant"))
+
+	// Cache hit: the existing value is returned
+	v, err = cache.GetOrSet(ctx, "elephant", theirs)
+	require.NoError(t, err)
+	assert.Equal(t, mine, v)
+
+	// Stale data is replaced
+	mr.FastForward(11 * time.Second)
+	v, err = cache.GetOrSet(ctx, "elephant", theirs)
+	require.NoError(t, err)
+	assert.Equal(t, theirs, v)
+
+	v, err = cache.Get(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		t.Error("unexpected fetch: cache should not have missed")
+		return testObj{}, errors.New("unexpected fetch")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, theirs, v)
+}
+
+func TestCacheGetOrSetNegative(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithNegativeCaching(5*time.Second))
+
+	require.NoError(t, cache.setNegative(ctx, "elephant"))
+
+	_, err := cache.GetOrSet(ctx, "elephant", testObj{Value: "my_value_for:elephant"})
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+	assert.False(t, mr.Exists("cache:data:objects:elephant"))
+}
+
+func TestCacheGetOrSetZeroValueForbidden(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	_, err := cache.GetOrSet(ctx, "elephant", testObj{})
+	assert.ErrorIs(t, err, ErrDisallowedCacheValue)
+}
+
+func TestCacheGetRaw(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithNegativeCaching(5*time.Second))
+
+	_, ok, err := cache.GetRaw(ctx, "elephant")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Data which can't be decoded is returned as stored
+	require.NoError(t, mr.Set("cache:data:objects:elephant", `{"value": 42}`))
+	raw, ok, err := cache.GetRaw(ctx, "elephant")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(`{"value": 42}`), raw)
+
+	require.NoError(t, cache.Set(ctx, "tuna", testObj{Value: "fish"}))
+	raw, ok, err = cache.GetRaw(ctx, "tuna")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(`{"value":"fish"}`), raw)
+
+	require.NoError(t, cache.setNegative(ctx, "tuna"))
+	_, ok, err = cache.GetRaw(ctx, "tuna")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMultipleCacheHedgedReads(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, slow := test.MiniRedis(t)
+	slow.AddHook(slowHook{delay: 10 * time.Second})
+	_, fast := test.MiniRedis(t)
+
+	obj := testObj{Value: "value_for:elephant_from_cache"}
+	require.NoError(t, NewCache[testObj](fast, "objects", 10*time.Second, 30*time.Second).Set(ctx, "elephant", obj))
+
+	cache := NewCacheMultipleBackends[testObj](
+		[]redis.Cmdable{slow, fast},
+		"objects",
+		10*time.Second,
+		30*time.Second,
+		WithHedgedReads(),
+	)
+
+	start := time.Now()
+	v, err := cache.Get(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		t.Error("unexpected fetch: cache should not have missed")
+		return testObj{}, errors.New("unexpected fetch")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, obj, v)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestMultipleCacheHedgedReadsPrefersFreshOverNegative(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb1 := test.MiniRedis(t)
+	_, rdb2 := test.MiniRedis(t)
+
+	obj := testObj{Value: "value_for:elephant_from_cache"}
+	require.NoError(t, NewCache[testObj](rdb1, "objects", 10*time.Second, 30*time.Second).Set(ctx, "elephant", obj))
+	require.NoError(t, NewCache[testObj](rdb2, "objects", 10*time.Second, 30*time.Second, WithNegativeCaching(time.Minute)).setNegative(ctx, "elephant"))
+
+	cache := NewCacheMultipleBackends[testObj](
+		[]redis.Cmdable{rdb2, rdb1},
+		"objects",
+		10*time.Second,
+		30*time.Second,
+		WithNegativeCaching(time.Minute),
+		WithHedgedReads(),
+	)
+
+	v, err := cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	assert.Equal(t, obj, v)
+}
+
+func TestCacheGetMulti(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithNegativeCaching(5*time.Second))
+
+	require.NoError(t, cache.Set(ctx, "elephant", testObj{Value: "cached_value_for:elephant"}))
+	require.NoError(t, cache.setNegative(ctx, "unicorn"))
+
+	var calls [][]string
+	fetcher := func(_ context.Context, keys []string) (map[string]testObj, error) {
+		calls = append(calls, keys)
+		values := make(map[string]testObj)
+		for _, k := range keys {
+			if k != "dodo" {
+				values[k] = testObj{Value: "value_for:" + k}
+			}
+		}
+		return values, nil
+	}
+
+	values, err := cache.GetMulti(ctx, []string{"elephant", "unicorn", "tuna", "dodo", "tuna"}, fetcher)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]testObj{
+		"elephant": {Value: "cached_value_for:elephant"},
+		"tuna":     {Value: "value_for:tuna"},
+	}, values)
+	assert.Equal(t, [][]string{{"tuna", "dodo"}}, calls)
+
+	// Fetched values are cached, as is the non-existence of omitted keys
+	assert.True(t, mr.Exists("cache:fresh:objects:tuna"))
+	assert.True(t, mr.Exists("cache:negative:objects:dodo"))
+
+	// Now everything is served from the cache in a single command
+	before := mr.CommandCount()
+	values, err = cache.GetMulti(ctx, []string{"elephant", "unicorn", "tuna", "dodo"}, fetcher)
+	require.NoError(t, err)
+	assert.Len(t, values, 2)
+	assert.Len(t, calls, 1)
+	assert.Equal(t, 1, mr.CommandCount()-before)
+}
+
+func TestCacheGetMultiServesStaleAndRefreshes(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	require.NoError(t, cache.Set(ctx, "elephant", testObj{Value: "old_value_for:elephant"}))
+	mr.FastForward(11 * time.Second)
+
+	var calls atomic.Int32
+	fetcher := func(_ context.Context, keys []string) (map[string]testObj, error) {
+		calls.Add(1)
+		values := make(map[string]testObj)
+		for _, k := range keys {
+			values[k] = testObj{Value: "new_value_for:" + k}
+		}
+		return values, nil
+	}
+
+	values, err := cache.GetMulti(ctx, []string{"elephant"}, fetcher)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]testObj{"elephant": {Value: "old_value_for:elephant"}}, values)
+
+	// The stale value is refreshed in the background
+	require.Eventually(t, func() bool {
+		return mr.Exists("cache:fresh:objects:elephant")
+	}, time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 1, calls.Load())
+
+	values, err = cache.GetMulti(ctx, []string{"elephant"}, fetcher)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]testObj{"elephant": {Value: "new_value_for:elephant"}}, values)
+}
+
+func TestCacheGetMultiFallsBackToFetcherOnError(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+	mr.Close()
+
+	values, err := cache.GetMulti(ctx, []string{"elephant"}, func(_ context.Context, keys []string) (map[string]testObj, error) {
+		return map[string]testObj{"elephant": {Value: "value_for:elephant"}}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]testObj{"elephant": {Value: "value_for:elephant"}}, values)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func TestCacheWithCodec(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithCodec(gobCodec{}))
+
+	obj := testObj{Value: "value_for:elephant"}
+	require.NoError(t, cache.Set(ctx, "elephant", obj))
+
+	raw, ok, err := cache.GetRaw(ctx, "elephant")
+	require.NoError(t, err)
+	require.True(t, ok)
+	var decoded testObj
+	require.NoError(t, gobCodec{}.Unmarshal(raw, &decoded))
+	assert.Equal(t, obj, decoded)
+
+	v, err := cache.Get(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		t.Error("unexpected fetch: cache should not have missed")
+		return testObj{}, errors.New("unexpected fetch")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, obj, v)
+
+	// Zero values are still rejected before being encoded
+	err = cache.Set(ctx, "tuna", testObj{})
+	assert.ErrorIs(t, err, ErrDisallowedCacheValue)
+}
+
+func useOutcomeCounters(t *testing.T) *sdkmetric.ManualReader {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+	m := mp.Meter("test")
+
+	for name, counter := range map[string]*metric.Int64Counter{
+		"cache.hits":          &hits,
+		"cache.soft_misses":   &softMisses,
+		"cache.hard_misses":   &hardMisses,
+		"cache.negative_hits": &negativeHits,
+		"cache.fill_errors":   &fillErrors,
+
+		"cache.refresh_lock_acquired":  &refreshLockAcquired,
+		"cache.refresh_lock_contended": &refreshLockContended,
+	} {
+		orig := *counter
+		c, err := m.Int64Counter(name)
+		require.NoError(t, err)
+		*counter = c
+		t.Cleanup(func() { *counter = orig })
+	}
+
+	return reader
+}
+
+func collectCounters(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	counts := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			for _, dp := range sum.DataPoints {
+				name, _ := dp.Attributes.Value("cache.name")
+				assert.Equal(t, "objects", name.AsString())
+				counts[m.Name] += dp.Value
+			}
+		}
+	}
+	return counts
+}
+
+func TestCacheMetrics(t *testing.T) {
+	ctx := test.Context(t)
+	reader := useOutcomeCounters(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithNegativeCaching(5*time.Second), WithMetrics())
+
+	// hard miss
+	_, err := cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	// hit
+	_, err = cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	// hard miss, cached as non-existent
+	_, err = cache.Get(ctx, "unicorn", func(context.Context, string) (testObj, error) {
+		return testObj{}, ErrDoesNotExist
+	})
+	require.ErrorIs(t, err, ErrDoesNotExist)
+	// negative hit
+	_, err = cache.Get(ctx, "unicorn", fetchTestObj)
+	require.ErrorIs(t, err, ErrDoesNotExist)
+	// hard miss, fill error
+	_, err = cache.Get(ctx, "tuna", func(context.Context, string) (testObj, error) {
+		return testObj{}, errors.New("upstream unavailable")
+	})
+	require.Error(t, err)
+	// soft miss
+	mr.FastForward(11 * time.Second)
+	_, err = cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int64{
+		"cache.hits":          1,
+		"cache.soft_misses":   1,
+		"cache.hard_misses":   3,
+		"cache.negative_hits": 1,
+		"cache.fill_errors":   1,
+
+		"cache.refresh_lock_acquired": 1,
+	}, collectCounters(t, reader))
+}
+
+func TestCacheMetricsDisabledByDefault(t *testing.T) {
+	ctx := test.Context(t)
+	reader := useOutcomeCounters(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	_, err := cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	_, err = cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+
+	assert.Empty(t, collectCounters(t, reader))
+}
+
+func TestCacheWithSchemaVersion(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	v1 := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithSchemaVersion("1"))
+	v2 := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithSchemaVersion("2"))
+	unversioned := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	require.NoError(t, v1.Set(ctx, "elephant", testObj{Value: "v1_value_for:elephant"}))
+	assert.True(t, mr.Exists("cache:data:objects@1:elephant"))
+
+	// Entries written under one version are misses under another
+	v, err := v2.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	assert.Equal(t, "value_for:elephant", v.Value)
+
+	_, ok, err := unversioned.GetRaw(ctx, "elephant")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// ...and don't overwrite one another
+	v, err = v1.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	assert.Equal(t, "v1_value_for:elephant", v.Value)
+}
+
+func TestCacheSetWithTTL(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	require.NoError(t, cache.SetWithTTL(ctx, "elephant", testObj{Value: "value_for:elephant"}, time.Hour, 2*time.Hour))
+	assert.Equal(t, time.Hour, mr.TTL("cache:fresh:objects:elephant"))
+	assert.Equal(t, 2*time.Hour, mr.TTL("cache:data:objects:elephant"))
+
+	// The default path is unaffected
+	require.NoError(t, cache.Set(ctx, "tuna", testObj{Value: "value_for:tuna"}))
+	assert.Equal(t, 10*time.Second, mr.TTL("cache:fresh:objects:tuna"))
+	assert.Equal(t, 30*time.Second, mr.TTL("cache:data:objects:tuna"))
+
+	err := cache.SetWithTTL(ctx, "elephant", testObj{Value: "value_for:elephant"}, time.Hour, time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidTTL)
+	err = cache.SetWithTTL(ctx, "elephant", testObj{Value: "value_for:elephant"}, 0, time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidTTL)
+	err = cache.SetWithTTL(ctx, "elephant", testObj{}, time.Hour, 2*time.Hour)
+	assert.ErrorIs(t, err, ErrDisallowedCacheValue)
+}
+
+func TestCacheSetWithOptions(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	require.NoError(t, cache.SetWithOptions(ctx, "elephant", testObj{Value: "value_for:elephant"}, SetOptions{Fresh: time.Hour, Stale: 2 * time.Hour}))
+	assert.Equal(t, time.Hour, mr.TTL("cache:fresh:objects:elephant"))
+	assert.Equal(t, 2*time.Hour, mr.TTL("cache:data:objects:elephant"))
+
+	// Unset durations fall back to the cache's own
+	require.NoError(t, cache.SetWithOptions(ctx, "tuna", testObj{Value: "value_for:tuna"}, SetOptions{Stale: time.Hour}))
+	assert.Equal(t, 10*time.Second, mr.TTL("cache:fresh:objects:tuna"))
+	assert.Equal(t, time.Hour, mr.TTL("cache:data:objects:tuna"))
+
+	err := cache.SetWithOptions(ctx, "elephant", testObj{Value: "value_for:elephant"}, SetOptions{Fresh: time.Minute})
+	assert.ErrorIs(t, err, ErrInvalidTTL)
+	err = cache.SetWithOptions(ctx, "elephant", testObj{Value: "value_for:elephant"}, SetOptions{Fresh: -time.Minute})
+	assert.ErrorIs(t, err, ErrInvalidTTL)
+}
+
+func TestCacheSetDoesNotExist(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithNegativeCaching(time.Minute))
+
+	require.NoError(t, cache.Set(ctx, "elephant", testObj{Value: "value_for:elephant"}))
+	require.NoError(t, cache.SetDoesNotExist(ctx, "elephant", SetOptions{Negative: time.Hour}))
+	assert.False(t, mr.Exists("cache:fresh:objects:elephant"))
+	assert.False(t, mr.Exists("cache:data:objects:elephant"))
+	assert.Equal(t, time.Hour, mr.TTL("cache:negative:objects:elephant"))
+
+	_, err := cache.Get(ctx, "elephant", func(ctx context.Context, key string) (testObj, error) {
+		t.Fatal("fetcher should not be called for a negatively cached key")
+		return testObj{}, nil
+	})
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+
+	// Without an override the configured negative TTL is used
+	require.NoError(t, cache.SetDoesNotExist(ctx, "tuna", SetOptions{}))
+	assert.Equal(t, time.Minute, mr.TTL("cache:negative:objects:tuna"))
+
+	err = cache.SetDoesNotExist(ctx, "tuna", SetOptions{Negative: -time.Minute})
+	assert.ErrorIs(t, err, ErrInvalidTTL)
+}
+
+func TestCacheSetDoesNotExistWithoutNegativeCaching(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	require.NoError(t, cache.Set(ctx, "elephant", testObj{Value: "value_for:elephant"}))
+	require.NoError(t, cache.SetDoesNotExist(ctx, "elephant", SetOptions{}))
+	assert.False(t, mr.Exists("cache:data:objects:elephant"))
+	assert.False(t, mr.Exists("cache:negative:objects:elephant"))
+
+	// An override enables negative caching for this write only
+	require.NoError(t, cache.SetDoesNotExist(ctx, "elephant", SetOptions{Negative: time.Minute}))
+	assert.Equal(t, time.Minute, mr.TTL("cache:negative:objects:elephant"))
+}
+
+func TestCacheWarm(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	err := cache.Warm(ctx, map[string]testObj{
+		"elephant": {Value: "value_for:elephant"},
+		"tuna":     {Value: "value_for:tuna"},
+		"unicorn":  {},
+	})
+	assert.ErrorIs(t, err, ErrDisallowedCacheValue)
+	assert.ErrorContains(t, err, "unicorn")
+
+	for _, k := range []string{"elephant", "tuna"} {
+		assert.Equal(t, 10*time.Second, mr.TTL("cache:fresh:objects:"+k))
+		v, err := cache.Get(ctx, k, func(context.Context, string) (testObj, error) {
+			t.Error("unexpected fetch: cache should not have missed")
+			return testObj{}, errors.New("unexpected fetch")
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "value_for:"+k, v.Value)
+	}
+	assert.False(t, mr.Exists("cache:data:objects:unicorn"))
+}
+
+func TestMultipleCacheWarm(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr1, rdb1 := test.MiniRedis(t)
+	mr2, rdb2 := test.MiniRedis(t)
+	cache := NewCacheMultipleBackends[testObj]([]redis.Cmdable{rdb1, rdb2}, "objects", 10*time.Second, 30*time.Second)
+
+	err := cache.Warm(ctx, map[string]testObj{
+		"elephant": {Value: "value_for:elephant"},
+		"tuna":     {Value: "value_for:tuna"},
+	})
+	require.NoError(t, err)
+
+	for _, mr := range []*miniredis.Miniredis{mr1, mr2} {
+		assert.True(t, mr.Exists("cache:data:objects:elephant"))
+		assert.True(t, mr.Exists("cache:data:objects:tuna"))
+		// The write locks have been released
+		assert.False(t, mr.Exists("cache:lock-multiple:objects:elephant"))
+		assert.False(t, mr.Exists("cache:lock-multiple:objects:tuna"))
+	}
+}
+
+func TestCacheWithTTLJitter(t *testing.T) {
+	ctx := test.Context(t)
+
+	fresh := 10 * time.Second
+	stale := 30 * time.Second
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", fresh, stale, WithTTLJitter(0.5))
+
+	freshTTLs := make(map[time.Duration]struct{})
+	for i := range 20 {
+		key := fmt.Sprintf("key%d", i)
+		require.NoError(t, cache.Set(ctx, key, testObj{Value: "value_for:" + key}))
+
+		f := mr.TTL("cache:fresh:objects:" + key)
+		s := mr.TTL("cache:data:objects:" + key)
+		assert.GreaterOrEqual(t, f, fresh/2)
+		assert.LessOrEqual(t, f, fresh*3/2)
+		assert.GreaterOrEqual(t, s, stale/2)
+		assert.LessOrEqual(t, s, stale*3/2)
+		assert.Greater(t, s, f)
+		freshTTLs[f] = struct{}{}
+	}
+	assert.Greater(t, len(freshTTLs), 1, "TTLs should vary")
+}
+
+func TestCacheTTLJitterKeepsStaleWindow(t *testing.T) {
+	_, rdb := test.MiniRedis(t)
+
+	for _, tc := range []struct {
+		fresh, stale, gap time.Duration
+	}{
+		{10 * time.Second, 11 * time.Second, time.Second},
+		{10 * time.Second, 30 * time.Second, time.Second},
+		{10 * time.Second, 10*time.Second + 100*time.Millisecond, 100 * time.Millisecond},
+	} {
+		cache := NewCache[testObj](rdb, "objects", tc.fresh, tc.stale, WithTTLJitter(0.9))
+		for range 1000 {
+			f, s := cache.jitter(tc.fresh, tc.stale)
+			require.Greater(t, s, f)
+			require.GreaterOrEqual(t, s-f, tc.gap)
+		}
+	}
+}
+
+func TestWithTTLJitterValidatesFraction(t *testing.T) {
+	_, rdb := test.MiniRedis(t)
+	for _, f := range []float64{-0.1, 1, 1.5} {
+		assert.Panics(t, func() {
+			NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithTTLJitter(f))
+		}, "fraction %v", f)
+	}
+	assert.NotPanics(t, func() {
+		NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithTTLJitter(0))
+	})
+}
+
+func TestCacheStats(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithNegativeCaching(5*time.Second))
+	other := NewCache[testObj](rdb, "others", 10*time.Second, 30*time.Second)
+
+	_, err := cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	_, err = cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	_, err = cache.Get(ctx, "unicorn", func(context.Context, string) (testObj, error) {
+		return testObj{}, ErrDoesNotExist
+	})
+	require.ErrorIs(t, err, ErrDoesNotExist)
+	_, err = cache.Get(ctx, "unicorn", fetchTestObj)
+	require.ErrorIs(t, err, ErrDoesNotExist)
+	require.NoError(t, other.Set(ctx, "tuna", testObj{Value: "value_for:tuna"}))
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{
+		Hits:         1,
+		HardMisses:   2,
+		NegativeHits: 1,
+		Fills:        1,
+		Keys:         1,
+	}, stats)
+}
+
+func TestCacheCoalescesConcurrentFills(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	fetcher := func(ctx context.Context, key string) (testObj, error) {
+		calls.Add(1)
+		<-release
+		return fetchTestObj(ctx, key)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]testObj, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.Get(ctx, "elephant", fetcher)
+			assert.NoError(t, err)
+			results[i] = v
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, calls.Load())
+	for _, v := range results {
+		assert.Equal(t, testObj{Value: "value_for:elephant"}, v)
+	}
+}
+
+func TestCacheFillRecoversFetcherPanic(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	_, err := cache.Get(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		panic("boom")
+	})
+	require.ErrorIs(t, err, ErrFetcherPanicked)
+	assert.ErrorContains(t, err, "boom")
+
+	// The cache still works afterwards
+	v, err := cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	assert.Equal(t, testObj{Value: "value_for:elephant"}, v)
+}
+
+func TestCacheFillSurvivesFirstCallerCancellation(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetcher := func(ctx context.Context, key string) (testObj, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return testObj{}, err
+		}
+		return fetchTestObj(ctx, key)
+	}
+
+	firstCtx, cancel := context.WithCancel(ctx)
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(firstCtx, "elephant", fetcher)
+		firstErr <- err
+	}()
+	<-started
+
+	type result struct {
+		v   testObj
+		err error
+	}
+	second := make(chan result, 1)
+	go func() {
+		v, err := cache.Get(ctx, "elephant", fetcher)
+		second <- result{v, err}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+
+	close(release)
+	res := <-second
+	require.NoError(t, res.err)
+	assert.Equal(t, testObj{Value: "value_for:elephant"}, res.v)
+}
+
+func TestCacheWithBlockingFirstFill(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+
+	var calls atomic.Int64
+	fetcher := func(ctx context.Context, key string) (testObj, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return fetchTestObj(ctx, key)
+	}
+
+	// Separate caches stand in for separate processes, whose fills aren't
+	// coalesced in memory.
+	var wg sync.WaitGroup
+	results := make([]testObj, 5)
+	for i := range results {
+		cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithBlockingFirstFill())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.Get(ctx, "elephant", fetcher)
+			assert.NoError(t, err)
+			results[i] = v
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 1, calls.Load())
+	for _, v := range results {
+		assert.Equal(t, testObj{Value: "value_for:elephant"}, v)
+	}
+}
+
+func TestNewCacheEValidatesName(t *testing.T) {
+	_, rdb := test.MiniRedis(t)
+
+	for _, name := range []string{"objects", "my-objects", "objects_v2", "objects.v2"} {
+		c, err := NewCacheE[testObj](rdb, name, 10*time.Second, 30*time.Second)
+		require.NoError(t, err, name)
+		assert.NotNil(t, c, name)
+	}
+
+	for _, name := range []string{"", "foo:bar", "foo bar", "foo\tbar", "foo@1", "foo*", "foo?", "foo[0]", `foo\bar`} {
+		c, err := NewCacheE[testObj](rdb, name, 10*time.Second, 30*time.Second)
+		assert.ErrorIs(t, err, ErrInvalidName, name)
+		assert.Nil(t, c, name)
+	}
+}
+
+func TestNewCacheEValidatesSchemaVersion(t *testing.T) {
+	_, rdb := test.MiniRedis(t)
+
+	for _, v := range []string{"1:2", "1 2", "1@2", "1*", "1?", "[1]", `1\2`} {
+		c, err := NewCacheE[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithSchemaVersion(v))
+		assert.ErrorIs(t, err, ErrInvalidName, v)
+		assert.Nil(t, c, v)
+	}
+
+	c, err := NewCacheE[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithSchemaVersion("v2.1"))
+	require.NoError(t, err)
+	assert.NotNil(t, c)
+
+	assert.Panics(t, func() {
+		NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithSchemaVersion("1:2"))
+	})
+}
+
+func TestNewCachePanicsOnInvalidName(t *testing.T) {
+	_, rdb := test.MiniRedis(t)
+
+	assert.Panics(t, func() {
+		NewCache[testObj](rdb, "foo:bar", 10*time.Second, 30*time.Second)
+	})
+	assert.Panics(t, func() {
+		NewCacheMultipleBackends[testObj]([]redis.Cmdable{rdb}, "", 10*time.Second, 30*time.Second)
+	})
+}
+
+func TestCacheGetWithBypass(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithNegativeCaching(5*time.Second))
+
+	require.NoError(t, cache.Set(ctx, "elephant", testObj{Value: "old_value_for:elephant"}))
+
+	v, err := cache.Get(WithBypass(ctx), "elephant", fetchTestObj)
+	require.NoError(t, err)
+	assert.Equal(t, testObj{Value: "value_for:elephant"}, v)
+
+	// The fresh value was written to the cache.
+	v, err = cache.Get(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		t.Fatal("unexpected fetch")
+		return testObj{}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testObj{Value: "value_for:elephant"}, v)
+
+	// Non-existence is still negatively cached.
+	_, err = cache.Get(WithBypass(ctx), "elephant", func(context.Context, string) (testObj, error) {
+		return testObj{}, ErrDoesNotExist
+	})
+	require.ErrorIs(t, err, ErrDoesNotExist)
+	assert.False(t, mr.Exists("cache:data:objects:elephant"))
+	assert.True(t, mr.Exists("cache:negative:objects:elephant"))
+}
+
+func TestCacheRefresh(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	require.NoError(t, cache.Set(ctx, "elephant", testObj{Value: "old_value_for:elephant"}))
+	require.NoError(t, cache.Refresh(ctx, "elephant", fetchTestObj))
+
+	v, ok, err := cache.GetRaw(ctx, "elephant")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.JSONEq(t, `{"value":"value_for:elephant"}`, string(v))
+	assert.False(t, mr.Exists("cache:lock:objects:elephant"))
+}
+
+func TestCacheRefreshWhenLockHeld(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second)
+
+	require.NoError(t, mr.Set("cache:lock:objects:elephant", "someone-else"))
+
+	err := cache.Refresh(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		t.Error("unexpected fetch: lock was held")
+		return testObj{}, errors.New("unexpected fetch")
+	})
+	require.ErrorIs(t, err, lock.ErrLockNotAcquired)
+}
+
+func TestMultipleCacheSetWithWriteLockTTL(t *testing.T) {
+	ctx := context.Background()
+
+	fresh := 10 * time.Second
+	stale := 30 * time.Second
+	lockTTL := 20 * time.Second
+
+	client1, mock1 := redismock.NewClientMock()
+	cacheMock1 := mockWrapper{
+		ClientMock: mock1,
+
+		name:    "objects",
+		fresh:   fresh,
+		stale:   stale,
+		lockTTL: lockTTL,
+	}
+	client2, mock2 := redismock.NewClientMock()
+	cacheMock2 := mockWrapper{
+		ClientMock: mock2,
+
+		name:    "objects",
+		fresh:   fresh,
+		stale:   stale,
+		lockTTL: lockTTL,
+	}
+	cache := NewCacheMultipleBackends[testObj]([]redis.Cmdable{client1, client2}, "objects", fresh, stale, WithWriteLockTTL(lockTTL))
+
+	obj := testObj{Value: "value_for:elephant"}
+
+	cacheMock1.ExpectCacheFillWithLock("elephant", obj)
+	cacheMock2.ExpectCacheFillWithLock("elephant", obj)
+
+	err := cache.Set(ctx, "elephant", obj)
+
+	assert.NoError(t, err)
+	assert.NoError(t, cacheMock1.ExpectationsWereMet())
+	assert.NoError(t, cacheMock2.ExpectationsWereMet())
+}
+
+func TestWithWriteLockTTLValidatesDuration(t *testing.T) {
+	_, rdb := test.MiniRedis(t)
+	for _, d := range []time.Duration{-time.Second, 0} {
+		assert.Panics(t, func() {
+			NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithWriteLockTTL(d))
+		}, "duration %v", d)
+	}
+}
+
+func TestCacheWithLocalTier(t *testing.T) {
+	ctx := test.Context(t)
+
+	mr, rdb := test.MiniRedis(t)
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithNegativeCaching(5*time.Second), WithLocalTier(10, time.Second))
+
+	now := time.Now()
+	cache.local.now = func() time.Time { return now }
+
+	mustNotFetch := func(context.Context, string) (testObj, error) {
+		t.Error("unexpected fetch")
+		return testObj{}, errors.New("unexpected fetch")
+	}
+
+	v, err := cache.Get(ctx, "elephant", fetchTestObj)
+	require.NoError(t, err)
+	assert.Equal(t, testObj{Value: "value_for:elephant"}, v)
+
+	// The value is served from memory even if Redis no longer has it...
+	mr.FlushAll()
+	v, err = cache.Get(ctx, "elephant", mustNotFetch)
+	require.NoError(t, err)
+	assert.Equal(t, testObj{Value: "value_for:elephant"}, v)
+
+	// ...until the local entry expires.
+	now = now.Add(time.Second)
+	v, err = cache.Get(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		return testObj{Value: "new_value_for:elephant"}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testObj{Value: "new_value_for:elephant"}, v)
+
+	// Writes replace the local entry.
+	require.NoError(t, cache.Set(ctx, "elephant", testObj{Value: "set_value_for:elephant"}))
+	mr.FlushAll()
+	v, err = cache.Get(ctx, "elephant", mustNotFetch)
+	require.NoError(t, err)
+	assert.Equal(t, testObj{Value: "set_value_for:elephant"}, v)
+
+	// Invalidation evicts the local entry.
+	_, err = cache.Refill(ctx, "elephant", func(context.Context, string) (testObj, error) {
+		return testObj{}, ErrDoesNotExist
+	})
+	require.ErrorIs(t, err, ErrDoesNotExist)
+	_, err = cache.Get(ctx, "elephant", mustNotFetch)
+	require.ErrorIs(t, err, ErrDoesNotExist)
+}
+
+func TestCacheWithLocalTierReturnsCopies(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	cache := NewCache[map[string]string](rdb, "objects", 10*time.Second, 30*time.Second, WithLocalTier(10, time.Minute))
+
+	value := map[string]string{"animal": "elephant"}
+	require.NoError(t, cache.Set(ctx, "elephant", value))
+
+	// Mutating the value passed to Set does not affect the cache...
+	value["animal"] = "tuna"
+
+	fetcher := func(context.Context, string) (map[string]string, error) {
+		t.Error("unexpected fetch")
+		return nil, errors.New("unexpected fetch")
+	}
+	v, err := cache.Get(ctx, "elephant", fetcher)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"animal": "elephant"}, v)
+
+	// ...and nor does mutating a value returned by Get.
+	v["animal"] = "giraffe"
+	v, err = cache.Get(ctx, "elephant", fetcher)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"animal": "elephant"}, v)
+}
+
+func TestWithLocalTierValidatesArguments(t *testing.T) {
+	_, rdb := test.MiniRedis(t)
+	assert.Panics(t, func() {
+		NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithLocalTier(0, time.Second))
+	})
+	assert.Panics(t, func() {
+		NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithLocalTier(10, 0))
+	})
+}
+
+func TestCacheRefreshLockMetrics(t *testing.T) {
+	ctx := test.Context(t)
+
+	reader := useOutcomeCounters(t)
+
+	mr, rdb := test.MiniRedis(t)
+
+	// Someone else is already refreshing this key.
+	lockClient, lockMock := redismock.NewClientMock()
+	lockMock.Regexp().ExpectSetNX("cache:lock:objects:elephant", `.*`, 30*time.Second).SetVal(false)
+
+	cache := NewCache[testObj](rdb, "objects", 10*time.Second, 30*time.Second, WithLocker(lock.Locker{Clients: []redis.Cmdable{lockClient}}), WithMetrics())
+
+	old := testObj{Value: "old_value_for:elephant"}
+	require.NoError(t, cache.Set(ctx, "elephant", old))
+	mr.FastForward(11 * time.Second)
+
+	var calls atomic.Int32
+	v, err := cache.Get(ctx, "elephant", func(ctx context.Context, key string) (testObj, error) {
+		calls.Add(1)
+		return fetchTestObj(ctx, key)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, old, v)
+	assert.NoError(t, lockMock.ExpectationsWereMet())
+	assert.Never(t, func() bool { return calls.Load() > 0 }, 50*time.Millisecond, 5*time.Millisecond)
+
+	assert.Equal(t, map[string]int64{
+		"cache.soft_misses":            1,
+		"cache.refresh_lock_contended": 1,
+	}, collectCounters(t, reader))
+}
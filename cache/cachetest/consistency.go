@@ -0,0 +1,168 @@
+// Package cachetest provides helpers for testing code which uses the cache
+// package, and for validating that a Redis deployment behaves as the cache
+// expects. It depends on the testing package, so it should only be imported
+// from tests.
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/replicate/go/cache"
+)
+
+const (
+	consistencyKeys       = 8
+	consistencyWorkers    = 8
+	consistencyIterations = 50
+)
+
+type consistencyValue struct {
+	Key string `json:"key"`
+	Seq int64  `json:"seq"`
+}
+
+// RunConsistencyCheck hammers a cache backed by rdb with concurrent reads,
+// writes, and deletions across a small set of keys, checking that it never
+// panics or serves values which weren't written for the requested key. Once
+// all background work has finished, it checks that writes and deletions are
+// reflected by subsequent reads, i.e. that positive and negative entries don't
+// coexist inconsistently.
+//
+// It is intended to allow users to validate that their Redis deployment
+// behaves as the cache expects. All keys written are removed at the end of the
+// check.
+func RunConsistencyCheck(t testing.TB, rdb redis.Cmdable) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	name := fmt.Sprintf("consistency-check-%d", time.Now().UnixNano())
+	c := cache.NewCache[consistencyValue](
+		rdb,
+		name,
+		10*time.Millisecond,
+		time.Second,
+		cache.WithNegativeCaching(time.Second),
+	)
+	require.NoError(t, c.Prepare(ctx))
+
+	keys := make([]string, consistencyKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if ks, err := scanKeys(ctx, rdb, "cache:*:"+name+":*"); err == nil && len(ks) > 0 {
+			_ = rdb.Del(ctx, ks...).Err()
+		}
+	})
+
+	var seq atomic.Int64
+	fetcher := func(_ context.Context, key string) (consistencyValue, error) {
+		if rand.Intn(10) == 0 {
+			return consistencyValue{}, cache.ErrDoesNotExist
+		}
+		return consistencyValue{Key: key, Seq: seq.Add(1)}, nil
+	}
+	deleter := func(context.Context, string) (consistencyValue, error) {
+		return consistencyValue{}, cache.ErrDoesNotExist
+	}
+
+	check := func(key string, v consistencyValue, err error) {
+		if errors.Is(err, cache.ErrDoesNotExist) {
+			return
+		}
+		if !assert.NoError(t, err, "key %s", key) {
+			return
+		}
+		assert.NotZero(t, v.Seq, "key %s: served zero value", key)
+		assert.Equal(t, key, v.Key, "key %s: served value for another key", key)
+	}
+
+	var wg sync.WaitGroup
+	for range consistencyWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("panic: %v", r)
+				}
+			}()
+
+			for range consistencyIterations {
+				key := keys[rand.Intn(len(keys))]
+				switch n := rand.Intn(10); {
+				case n < 5:
+					v, err := c.Get(ctx, key, fetcher)
+					check(key, v, err)
+				case n < 8:
+					err := c.Set(ctx, key, consistencyValue{Key: key, Seq: seq.Add(1)})
+					assert.NoError(t, err, "key %s", key)
+				default:
+					_, err := c.Refill(ctx, key, deleter)
+					assert.ErrorIs(t, err, cache.ErrDoesNotExist, "key %s", key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Wait for any background refreshes to finish.
+	require.Eventually(t, func() bool {
+		locks, err := scanKeys(ctx, rdb, "cache:lock:"+name+":*")
+		return err == nil && len(locks) == 0
+	}, 10*time.Second, 10*time.Millisecond, "locks not released")
+
+	mustNotFetch := func(_ context.Context, key string) (consistencyValue, error) {
+		t.Errorf("key %s: unexpected fetch", key)
+		return consistencyValue{}, errors.New("unexpected fetch")
+	}
+
+	for i, k := range keys {
+		if i%2 == 0 {
+			want := consistencyValue{Key: k, Seq: seq.Add(1)}
+			require.NoError(t, c.Set(ctx, k, want))
+
+			v, err := c.Get(ctx, k, mustNotFetch)
+			require.NoError(t, err, "key %s", k)
+			assert.Equal(t, want, v, "key %s: write not reflected by read", k)
+		} else {
+			_, err := c.Refill(ctx, k, deleter)
+			require.ErrorIs(t, err, cache.ErrDoesNotExist)
+
+			_, err = c.Get(ctx, k, mustNotFetch)
+			assert.ErrorIs(t, err, cache.ErrDoesNotExist, "key %s: deletion not reflected by read", k)
+		}
+	}
+}
+
+// scanKeys returns all the keys matching pattern. Keys are matched by pattern,
+// rather than computed, as the cache doesn't export its key layout.
+func scanKeys(ctx context.Context, rdb redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	var cursor uint64
+	for {
+		ks, next, err := rdb.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, ks...)
+		if next == 0 {
+			return keys, nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,13 @@
+package cachetest
+
+import (
+	"testing"
+
+	"github.com/replicate/go/test"
+)
+
+func TestRunConsistencyCheck(t *testing.T) {
+	_, rdb := test.MiniRedis(t)
+
+	RunConsistencyCheck(t, rdb)
+}
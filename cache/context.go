@@ -0,0 +1,22 @@
+package cache
+
+import "context"
+
+type contextKey int
+
+const (
+	bypassKey contextKey = iota
+)
+
+// WithBypass returns a child context which causes Cache.Get to skip reading
+// from the cache and instead fetch a fresh value and fill the cache with it, as
+// if it had been a hard miss. This is useful when the caller knows that the
+// cached value is stale, for example immediately after a write elsewhere.
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	b, _ := ctx.Value(bypassKey).(bool)
+	return b
+}
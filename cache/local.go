@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// localTier is a small in-process LRU cache of encoded values, checked before
+// Redis. See WithLocalTier.
+//
+// Values are stored encoded, as read from or written to Redis, and decoded on
+// every hit, so that callers never share a decoded value which one of them
+// might mutate.
+//
+// All methods are safe to call on a nil *localTier, which behaves as an empty
+// cache which discards writes.
+type localTier struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // of *localEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type localEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+func newLocalTier(size int, ttl time.Duration) *localTier {
+	return &localTier{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// get returns the value stored for key, if it has not expired.
+func (l *localTier) get(key string) (value string, ok bool) {
+	if l == nil {
+		return value, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.entries[key]
+	if !ok {
+		return value, false
+	}
+	entry := elem.Value.(*localEntry)
+	if !l.now().Before(entry.expires) {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+		return value, false
+	}
+	l.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// add stores value for key, evicting the least recently used entry if the tier
+// is full.
+func (l *localTier) add(key string, value string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expires := l.now().Add(l.ttl)
+	if elem, ok := l.entries[key]; ok {
+		entry := elem.Value.(*localEntry)
+		entry.value = value
+		entry.expires = expires
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	if l.order.Len() >= l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*localEntry).key)
+	}
+	l.entries[key] = l.order.PushFront(&localEntry{key: key, value: value, expires: expires})
+}
+
+// remove evicts any value stored for key.
+func (l *localTier) remove(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[key]; ok {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+	}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalTierEvictsLeastRecentlyUsed(t *testing.T) {
+	l := newLocalTier(2, time.Minute)
+
+	l.add("a", "1")
+	l.add("b", "2")
+	_, ok := l.get("a")
+	assert.True(t, ok)
+
+	// "b" is now the least recently used entry.
+	l.add("c", "3")
+
+	_, ok = l.get("b")
+	assert.False(t, ok)
+	v, ok := l.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+	v, ok = l.get("c")
+	assert.True(t, ok)
+	assert.Equal(t, "3", v)
+}
+
+func TestLocalTierExpiresEntries(t *testing.T) {
+	now := time.Now()
+	l := newLocalTier(2, time.Second)
+	l.now = func() time.Time { return now }
+
+	l.add("a", "1")
+	_, ok := l.get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = l.get("a")
+	assert.False(t, ok)
+	assert.Empty(t, l.entries)
+}
+
+func TestLocalTierRemove(t *testing.T) {
+	l := newLocalTier(2, time.Minute)
+
+	l.add("a", "1")
+	l.remove("a")
+	_, ok := l.get("a")
+	assert.False(t, ok)
+
+	// A nil tier is empty and discards writes.
+	var nl *localTier
+	nl.add("a", "1")
+	_, ok = nl.get("a")
+	assert.False(t, ok)
+	nl.remove("a")
+}
@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/replicate/go/logging"
+)
+
+// MultiFetcher fetches the values for several keys at once. Keys which do not
+// exist should be omitted from the returned map.
+type MultiFetcher[T any] func(ctx context.Context, keys []string) (map[string]T, error)
+
+type multiLookupResult struct {
+	data     any
+	fresh    bool
+	negative bool
+}
+
+// GetMulti fetches the items with the given keys from the cache, as Get, but
+// reads all of them in a single round trip to each backend. The fetcher is
+// called at most once, with all the keys which were missing from the cache.
+// Stale values are served and refreshed in the background individually, as
+// with Get.
+//
+// Keys which don't exist are omitted from the returned map, whether their
+// non-existence was cached or they were omitted from the fetcher's result. In
+// the latter case, non-existence is cached if negative caching is enabled.
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string, fetcher MultiFetcher[T]) (map[string]T, error) {
+	log := logger.With(logging.GetFields(ctx)...).Sugar()
+
+	if c == nil {
+		log.Warnf("cache not configured: fetching data directly")
+		return fetcher(ctx, keys)
+	}
+
+	results, err := c.lookupMulti(ctx, keys)
+	if err != nil {
+		log.Warnw("cache fetch failed: falling back to direct fetch", "error", err)
+		return fetcher(ctx, keys)
+	}
+
+	values := make(map[string]T, len(keys))
+	var missing []string
+	for _, key := range keys {
+		r, ok := results[key]
+		if !ok {
+			// duplicate key, already handled
+			continue
+		}
+		delete(results, key)
+
+		switch {
+		case r.negative:
+			c.count(ctx, outcomeNegativeHit)
+			continue
+		case r.data == nil:
+			c.count(ctx, outcomeHardMiss)
+			missing = append(missing, key)
+			continue
+		case r.fresh:
+			c.count(ctx, outcomeHit)
+		default:
+			c.count(ctx, outcomeSoftMiss)
+		}
+
+		value, err := c.decode(r.data)
+		if err != nil {
+			log.Warnw("cache decode failed: treating as miss", "cache.key", key, "error", err)
+			missing = append(missing, key)
+			continue
+		}
+		if !r.fresh {
+			c.serveStale(ctx, key, singleFetcher(fetcher))
+		}
+		values[key] = value
+	}
+
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	fetched, err := c.fillMulti(ctx, missing, fetcher)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range missing {
+		if v, ok := fetched[key]; ok {
+			values[key] = v
+		}
+	}
+	return values, nil
+}
+
+// lookupMulti retrieves the raw cached data for each of keys with a single MGET
+// per backend. As with lookup, the first positive result for each key is used.
+func (c *Cache[T]) lookupMulti(ctx context.Context, keys []string) (map[string]multiLookupResult, error) {
+	results := make(map[string]multiLookupResult, len(keys))
+	pending := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := results[key]; !ok {
+			results[key] = multiLookupResult{}
+			pending = append(pending, key)
+		}
+	}
+
+	for _, client := range c.clients {
+		if len(pending) == 0 {
+			break
+		}
+
+		mgetKeys := make([]string, 0, 3*len(pending))
+		for _, key := range pending {
+			ks := c.keysFor(key)
+			mgetKeys = append(mgetKeys, ks.fresh, ks.data, ks.negative)
+		}
+		vals, err := client.MGet(ctx, mgetKeys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != len(mgetKeys) {
+			return nil, fmt.Errorf("incorrect number of values from redis: got %d, expected %d", len(vals), len(mgetKeys))
+		}
+
+		var next []string
+		for i, key := range pending {
+			freshVal, data, negative := vals[3*i], vals[3*i+1], vals[3*i+2]
+			results[key] = multiLookupResult{
+				data:     data,
+				fresh:    freshVal != nil,
+				negative: negative != nil,
+			}
+			if freshVal == nil || data == nil {
+				// not a cache hit, so try the next backend
+				next = append(next, key)
+			}
+		}
+		pending = next
+	}
+
+	return results, nil
+}
+
+// fillMulti fetches the values for keys from upstream using the passed fetcher
+// and updates the cache. It is called with all the hard cache misses from
+// GetMulti.
+func (c *Cache[T]) fillMulti(ctx context.Context, keys []string, fetcher MultiFetcher[T]) (map[string]T, error) {
+	log := logger.With(logging.GetFields(ctx)...).Sugar()
+
+	ctx, span := tracer.Start(
+		ctx,
+		"cache.miss",
+		trace.WithAttributes(
+			attribute.String("cache.name", c.name),
+			attribute.StringSlice("cache.keys", keys),
+			attribute.String("cache.miss", "hard"),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	values, err := fetcher(ctx, keys)
+	c.recordFetchDuration(ctx, time.Since(start))
+	if err != nil {
+		c.count(ctx, outcomeFillError)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	for _, key := range keys {
+		value, ok := values[key]
+		if !ok {
+			err = c.setNegative(ctx, key)
+		} else {
+			err = c.set(ctx, key, value)
+		}
+		if err != nil {
+			// As in fill, errors updating the cache are not returned to the caller.
+			c.count(ctx, outcomeFillError)
+			span.SetStatus(codes.Error, err.Error())
+			log.Warnw("cache fill failed", "cache.key", key, "error", err)
+		} else if ok {
+			c.count(ctx, outcomeFill)
+		}
+	}
+
+	return values, nil
+}
+
+// singleFetcher adapts a MultiFetcher to fetch a single key, for use in
+// background refreshes.
+func singleFetcher[T any](fetcher MultiFetcher[T]) Fetcher[T] {
+	return func(ctx context.Context, key string) (value T, err error) {
+		values, err := fetcher(ctx, []string{key})
+		if err != nil {
+			return value, err
+		}
+		value, ok := values[key]
+		if !ok {
+			return value, ErrDoesNotExist
+		}
+		return value, nil
+	}
+}
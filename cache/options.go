@@ -1,15 +1,35 @@
 package cache
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"github.com/replicate/go/lock"
+)
 
 type Option interface {
 	apply(*cacheOptions)
 }
 
 type cacheOptions struct {
-	Fresh    time.Duration
-	Stale    time.Duration
-	Negative time.Duration
+	Fresh             time.Duration
+	Stale             time.Duration
+	Negative          time.Duration
+	ServeStaleOnError bool
+	AllowZeroValue    bool
+	Locker            *lock.Locker
+	LogStaleServes    bool
+	HedgedReads       bool
+	Codec             Codec
+	Metrics           bool
+	SchemaVersion     string
+	TTLJitter         float64
+	WriteLockTTL      time.Duration
+	LocalSize         int
+	LocalTTL          time.Duration
+	BlockingFirstFill bool
+
+	FetchDurationMetric bool
 }
 
 type optionFunc func(*cacheOptions)
@@ -25,3 +45,176 @@ func WithNegativeCaching(duration time.Duration) Option {
 		opts.Negative = duration
 	})
 }
+
+// WithServeStaleOnError configures the cache to retain stale data when a
+// background refresh fails. If the fetcher returns an error while refreshing a
+// stale entry, the entry's expiry is extended by the stale duration so that it
+// continues to be served, rather than being allowed to expire and causing hard
+// misses while the upstream is unavailable.
+func WithServeStaleOnError() Option {
+	return optionFunc(func(opts *cacheOptions) {
+		opts.ServeStaleOnError = true
+	})
+}
+
+// WithFetchDurationMetric configures the cache to record the duration of calls
+// to fetchers in the cache.fetch.duration histogram, in addition to the
+// cache.fetch_duration_ms span attribute which is always recorded.
+func WithFetchDurationMetric() Option {
+	return optionFunc(func(opts *cacheOptions) {
+		opts.FetchDurationMetric = true
+	})
+}
+
+// WithMetrics configures the cache to count the outcome of reads in the
+// cache.hits, cache.soft_misses, cache.hard_misses and cache.negative_hits
+// metrics, failures to fill the cache after a hard miss in cache.fill_errors,
+// and whether soft misses acquired the refresh lock in
+// cache.refresh_lock_acquired and cache.refresh_lock_contended. Each is tagged
+// with the cache name.
+func WithMetrics() Option {
+	return optionFunc(func(opts *cacheOptions) {
+		opts.Metrics = true
+	})
+}
+
+// WithAllowZeroValue configures the cache to accept the zero value of the cache
+// type T. By default zero values are rejected with ErrDisallowedCacheValue, as
+// they are often the result of a bug and could poison the cache. Only use this
+// option for types where the zero value is legitimate data.
+func WithAllowZeroValue() Option {
+	return optionFunc(func(opts *cacheOptions) {
+		opts.AllowZeroValue = true
+	})
+}
+
+// WithLocker configures the cache to use the given locker for the locks which
+// protect against stampedes when refreshing values, rather than one backed by
+// the cache's own Redis clients. This is primarily useful in tests, to control
+// lock contention deterministically.
+func WithLocker(locker lock.Locker) Option {
+	return optionFunc(func(opts *cacheOptions) {
+		opts.Locker = &locker
+	})
+}
+
+// WithStaleServeLogging configures the cache to log a message whenever stale
+// data is served (i.e. on a soft cache miss). Stale serves are counted in the
+// cache.soft_misses metric if the cache is configured WithMetrics.
+func WithStaleServeLogging() Option {
+	return optionFunc(func(opts *cacheOptions) {
+		opts.LogStaleServes = true
+	})
+}
+
+// WithHedgedReads configures a cache with multiple backends (see
+// NewCacheMultipleBackends) to query all the backends concurrently, rather than
+// in turn, and to use the first fresh value returned. This avoids a slow
+// backend delaying reads which another backend could serve, which is useful
+// when migrating between backends.
+func WithHedgedReads() Option {
+	return optionFunc(func(opts *cacheOptions) {
+		opts.HedgedReads = true
+	})
+}
+
+// WithCodec configures the cache to serialize values using codec rather than
+// the default JSONCodec. Note that changing the codec of an existing cache will
+// cause values stored with the old codec to fail to decode, so it is usually
+// best to change the cache name at the same time.
+func WithCodec(codec Codec) Option {
+	return optionFunc(func(opts *cacheOptions) {
+		opts.Codec = codec
+	})
+}
+
+// WithSchemaVersion configures the cache to store entries in a namespace
+// specific to the given version of the schema of T. Changing the version
+// effectively invalidates all entries written under other versions (they are
+// treated as misses), so it should be changed whenever T changes in a way
+// which is not compatible with existing cached data. Entries written under
+// other versions are left to expire.
+//
+// The version is subject to the same restrictions as the cache's name (see
+// NewCacheE).
+func WithSchemaVersion(v string) Option {
+	return optionFunc(func(opts *cacheOptions) {
+		opts.SchemaVersion = v
+	})
+}
+
+// WithTTLJitter configures the cache to randomly perturb the fresh and stale
+// durations of each value it stores by up to ±fraction of their configured
+// values, so that keys written at the same time don't all expire at the same
+// time. The two durations are perturbed independently, but the stale duration
+// is kept longer than the fresh duration (by up to a second, or the configured
+// difference if smaller), so that there is always a window in which stale data
+// is served while it is refreshed.
+//
+// The fraction must be in the range [0, 1). WithTTLJitter panics otherwise.
+func WithTTLJitter(fraction float64) Option {
+	return optionFunc(func(opts *cacheOptions) {
+		if fraction < 0 || fraction >= 1 {
+			panic(fmt.Sprintf("cache: TTL jitter fraction must be in [0, 1), got %v", fraction))
+		}
+		opts.TTLJitter = fraction
+	})
+}
+
+// WithWriteLockTTL configures how long a cache with multiple backends (see
+// NewCacheMultipleBackends) may hold the lock which serializes writes to a key
+// across backends, which is also the timeout for acquiring the lock and
+// completing the write. The default is 5 seconds, which may be too short for
+// large values or slow backends.
+//
+// The duration must be positive. WithWriteLockTTL panics otherwise.
+func WithWriteLockTTL(d time.Duration) Option {
+	return optionFunc(func(opts *cacheOptions) {
+		if d <= 0 {
+			panic(fmt.Sprintf("cache: write lock TTL must be positive, got %v", d))
+		}
+		opts.WriteLockTTL = d
+	})
+}
+
+// WithLocalTier configures the cache to keep up to size recently used values
+// in memory, and to serve them without a round trip to Redis for up to ttl
+// after they were read from or written to Redis by this process. This is
+// intended for extremely hot keys.
+//
+// Values written to the cache by other processes are not seen until the local
+// entry expires, so ttl bounds how stale the values served from memory can be
+// and should usually be much shorter than the fresh duration. Local entries
+// are evicted when this process writes to or invalidates the key.
+//
+// Values are kept in memory in encoded form and decoded on every hit, so as
+// with values read from Redis, each Get returns its own copy which the caller
+// is free to mutate.
+//
+// The size and ttl must be positive. WithLocalTier panics otherwise.
+func WithLocalTier(size int, ttl time.Duration) Option {
+	return optionFunc(func(opts *cacheOptions) {
+		if size <= 0 {
+			panic(fmt.Sprintf("cache: local tier size must be positive, got %d", size))
+		}
+		if ttl <= 0 {
+			panic(fmt.Sprintf("cache: local tier TTL must be positive, got %v", ttl))
+		}
+		opts.LocalSize = size
+		opts.LocalTTL = ttl
+	})
+}
+
+// WithBlockingFirstFill configures the cache to take the refresh lock when
+// filling the cache on a hard miss, as well as when refreshing it on a soft
+// miss. Concurrent hard misses for a key in different processes (e.g. when
+// many instances start with a cold cache) then wait for the first fill to
+// complete and read its result, rather than all calling the fetcher.
+//
+// Hard misses within a single process are always coalesced. If the lock cannot
+// be acquired within a few seconds, the cache is filled without it.
+func WithBlockingFirstFill() Option {
+	return optionFunc(func(opts *cacheOptions) {
+		opts.BlockingFirstFill = true
+	})
+}
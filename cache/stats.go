@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+type outcome int
+
+const (
+	outcomeHit outcome = iota
+	outcomeSoftMiss
+	outcomeHardMiss
+	outcomeNegativeHit
+	outcomeFill
+	outcomeFillError
+	outcomeRefreshLockAcquired
+	outcomeRefreshLockContended
+
+	numOutcomes
+)
+
+// metric returns the counter in which the outcome is recorded when the cache
+// is configured WithMetrics, or nil if there is none.
+func (o outcome) metric() metric.Int64Counter {
+	switch o {
+	case outcomeHit:
+		return hits
+	case outcomeSoftMiss:
+		return softMisses
+	case outcomeHardMiss:
+		return hardMisses
+	case outcomeNegativeHit:
+		return negativeHits
+	case outcomeFillError:
+		return fillErrors
+	case outcomeRefreshLockAcquired:
+		return refreshLockAcquired
+	case outcomeRefreshLockContended:
+		return refreshLockContended
+	default:
+		return nil
+	}
+}
+
+// CacheStats is a snapshot of a cache's activity, as returned by Stats.
+type CacheStats struct {
+	// Counts of read outcomes in this process since the cache was constructed.
+	Hits         int64 `json:"hits"`
+	SoftMisses   int64 `json:"soft_misses"`
+	HardMisses   int64 `json:"hard_misses"`
+	NegativeHits int64 `json:"negative_hits"`
+
+	// Counts of successful and failed attempts to fill the cache after a hard
+	// miss in this process since the cache was constructed.
+	Fills      int64 `json:"fills"`
+	FillErrors int64 `json:"fill_errors"`
+
+	// Keys is the approximate number of values stored in the cache, across all
+	// processes. It is only an estimate, as keys may be added or expire while
+	// they are being counted.
+	Keys int64 `json:"keys"`
+}
+
+// Stats returns a snapshot of the cache's activity in this process, along with
+// an estimate of the number of values stored in the (primary) Redis.
+//
+// Counting keys requires scanning the Redis keyspace, which is expensive for
+// large databases, so Stats should be called sparingly.
+func (c *Cache[T]) Stats(ctx context.Context) (CacheStats, error) {
+	if c == nil {
+		return CacheStats{}, nil
+	}
+
+	stats := CacheStats{
+		Hits:         c.counters[outcomeHit].Load(),
+		SoftMisses:   c.counters[outcomeSoftMiss].Load(),
+		HardMisses:   c.counters[outcomeHardMiss].Load(),
+		NegativeHits: c.counters[outcomeNegativeHit].Load(),
+		Fills:        c.counters[outcomeFill].Load(),
+		FillErrors:   c.counters[outcomeFillError].Load(),
+	}
+
+	pattern := c.keysFor("*").data
+	iter := c.clients[0].Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		stats.Keys++
+	}
+	if err := iter.Err(); err != nil {
+		return stats, fmt.Errorf("error counting cache keys: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/replicate/go/ratelimit"
+)
+
+const usage = `usage: ratelimit [flags] <command> <key>
+
+commands:
+  take     take tokens from the bucket and print the result
+  set      set the rate and capacity for the bucket
+  inspect  print the current state of the bucket
+
+flags:
+`
+
+var errUsage = errors.New("invalid usage")
+
+type config struct {
+	tokens   int
+	rate     int
+	capacity int
+}
+
+func main() {
+	redisURL := flag.String("redis-url", envOr("REDIS_URL", "redis://localhost:6379"), "URL of the Redis server (default: $REDIS_URL)")
+	tokens := flag.Int("tokens", 1, "number of tokens to take")
+	rate := flag.Int("rate", 10, "bucket fill rate in tokens per second")
+	capacity := flag.Int("capacity", 100, "bucket capacity")
+
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	opts, err := redis.ParseURL(*redisURL)
+	if err != nil {
+		fmt.Println("error parsing redis url:", err)
+		os.Exit(1)
+	}
+	rdb := redis.NewClient(opts)
+	defer rdb.Close()
+
+	cfg := config{tokens: *tokens, rate: *rate, capacity: *capacity}
+	if err := run(context.Background(), rdb, os.Stdout, cfg, flag.Args()); err != nil {
+		if errors.Is(err, errUsage) {
+			flag.Usage()
+		} else {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, rdb redis.Cmdable, w io.Writer, cfg config, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	command, key := args[0], args[1]
+
+	limiter, err := ratelimit.NewLimiter(rdb)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "take":
+		r, err := limiter.Take(ctx, key, cfg.tokens, cfg.rate, cfg.capacity)
+		if err != nil {
+			return fmt.Errorf("error taking tokens: %w", err)
+		}
+		fmt.Fprintf(w, "ok=%t tokens=%d remaining=%d reset=%s\n", r.OK, r.Tokens, r.Remaining, r.Reset)
+	case "set":
+		if err := limiter.SetOptions(ctx, key, cfg.rate, cfg.capacity); err != nil {
+			return fmt.Errorf("error setting options: %w", err)
+		}
+		fmt.Fprintf(w, "rate=%d capacity=%d\n", cfg.rate, cfg.capacity)
+	case "inspect":
+		return inspect(ctx, rdb, w, key)
+	default:
+		return errUsage
+	}
+	return nil
+}
+
+func inspect(ctx context.Context, rdb redis.Cmdable, w io.Writer, key string) error {
+	state, err := rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("error reading bucket: %w", err)
+	}
+	if len(state) == 0 {
+		fmt.Fprintln(w, "bucket does not exist")
+		return nil
+	}
+	ttl, err := rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("error reading bucket ttl: %w", err)
+	}
+
+	fields := make([]string, 0, len(state))
+	for k := range state {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, k := range fields {
+		fmt.Fprintf(w, "%s=%s\n", k, state[k])
+	}
+	fmt.Fprintf(w, "ttl=%s\n", ttl)
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
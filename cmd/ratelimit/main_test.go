@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/replicate/go/test"
+)
+
+func TestRunTake(t *testing.T) {
+	ctx := test.Context(t)
+	_, rdb := test.MiniRedis(t)
+
+	cfg := config{tokens: 1, rate: 1, capacity: 2}
+	take := func() string {
+		var out bytes.Buffer
+		require.NoError(t, run(ctx, rdb, &out, cfg, []string{"take", "limit:test"}))
+		return out.String()
+	}
+
+	assert.Equal(t, "ok=true tokens=1 remaining=1 reset=1s\n", take())
+	assert.Equal(t, "ok=true tokens=1 remaining=0 reset=2s\n", take())
+	assert.Equal(t, "ok=false tokens=0 remaining=0 reset=2s\n", take())
+}
+
+func TestRunSetAndInspect(t *testing.T) {
+	ctx := test.Context(t)
+	_, rdb := test.MiniRedis(t)
+
+	var out bytes.Buffer
+	require.NoError(t, run(ctx, rdb, &out, config{}, []string{"inspect", "limit:test"}))
+	assert.Equal(t, "bucket does not exist\n", out.String())
+
+	out.Reset()
+	require.NoError(t, run(ctx, rdb, &out, config{rate: 5, capacity: 50}, []string{"set", "limit:test"}))
+	assert.Equal(t, "rate=5 capacity=50\n", out.String())
+
+	out.Reset()
+	require.NoError(t, run(ctx, rdb, &out, config{}, []string{"inspect", "limit:test"}))
+	assert.Equal(t, "capacity=50\nrate=5\nttl=1m0s\n", out.String())
+}
+
+func TestRunInvalidUsage(t *testing.T) {
+	ctx := test.Context(t)
+	_, rdb := test.MiniRedis(t)
+
+	var out bytes.Buffer
+	assert.ErrorIs(t, run(ctx, rdb, &out, config{}, []string{"take"}), errUsage)
+	assert.ErrorIs(t, run(ctx, rdb, &out, config{}, []string{"frobnicate", "limit:test"}), errUsage)
+}
@@ -1,42 +1,77 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
 	"github.com/replicate/go/uuid"
 )
 
+var errUsage = errors.New("invalid usage")
+
+type config struct {
+	count      int
+	timestamps bool
+	format     string
+}
+
+type record struct {
+	UUID      string `json:"uuid"`
+	Timestamp string `json:"timestamp"`
+}
+
 func main() {
 	count := flag.Int("count", 1, "number of uuids to create (default: 1)")
 	timestamps := flag.Bool("timestamps", false, "include timestamp in column (default: false)")
+	format := flag.String("format", "text", `output format: "text", or "json" for one object per line including the timestamp (default: text)`)
 
 	flag.Parse()
 
-	if *count < 0 {
-		fmt.Println("count cannot be less than 0")
+	cfg := config{count: *count, timestamps: *timestamps, format: *format}
+	if err := run(os.Stdout, cfg); err != nil {
+		fmt.Println(err)
+		if errors.Is(err, errUsage) {
+			flag.Usage()
+		}
 		os.Exit(1)
 	}
+}
+
+func run(w io.Writer, cfg config) error {
+	if cfg.count < 0 {
+		return fmt.Errorf("%w: count cannot be less than 0", errUsage)
+	}
+	if cfg.format != "text" && cfg.format != "json" {
+		return fmt.Errorf("%w: unknown format %q", errUsage, cfg.format)
+	}
 
-	for i := 1; i <= *count; i++ {
+	enc := json.NewEncoder(w)
+	for i := 1; i <= cfg.count; i++ {
 		u, err := uuid.NewV7()
 		if err != nil {
-			fmt.Println("error creating uuid: %w", err)
-			os.Exit(1)
+			return fmt.Errorf("error creating uuid: %w", err)
 		}
 
 		ts, err := uuid.TimeFromV7(u)
 		if err != nil {
-			fmt.Println("error extracting timestamp: %w", err)
-			os.Exit(1)
+			return fmt.Errorf("error extracting timestamp: %w", err)
 		}
 
-		if *timestamps {
-			fmt.Println(u, ts.Format(time.RFC3339Nano))
-		} else {
-			fmt.Println(u)
+		switch {
+		case cfg.format == "json":
+			if err := enc.Encode(record{UUID: u.String(), Timestamp: ts.Format(time.RFC3339Nano)}); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+		case cfg.timestamps:
+			fmt.Fprintln(w, u, ts.Format(time.RFC3339Nano))
+		default:
+			fmt.Fprintln(w, u)
 		}
 	}
+	return nil
 }
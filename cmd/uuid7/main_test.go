@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var uuidV7 = regexp.MustCompile(`\A[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\z`)
+
+func TestRunText(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, run(&out, config{count: 3, format: "text"}))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	for _, line := range lines {
+		assert.Regexp(t, uuidV7, line)
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	start := time.Now().Truncate(time.Millisecond)
+
+	var out bytes.Buffer
+	require.NoError(t, run(&out, config{count: 3, format: "json"}))
+
+	n := 0
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		n++
+
+		var r map[string]string
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		require.Contains(t, r, "uuid")
+		require.Contains(t, r, "timestamp")
+
+		assert.Regexp(t, uuidV7, r["uuid"])
+		ts, err := time.Parse(time.RFC3339Nano, r["timestamp"])
+		require.NoError(t, err)
+		assert.WithinRange(t, ts, start, time.Now())
+	}
+	assert.Equal(t, 3, n)
+}
+
+func TestRunInvalidUsage(t *testing.T) {
+	var out bytes.Buffer
+	assert.ErrorIs(t, run(&out, config{count: -1, format: "text"}), errUsage)
+	assert.ErrorIs(t, run(&out, config{count: 1, format: "yaml"}), errUsage)
+	assert.Empty(t, out.String())
+}
@@ -6,9 +6,13 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/replicate/go/logging"
+	"github.com/replicate/go/telemetry"
 )
 
 const Addr = "localhost:7878"
@@ -32,7 +36,9 @@ func init() {
 	HandleFunc("/debug/pprof/profile", pprof.Profile)
 	HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 	HandleFunc("/debug/pprof/trace", pprof.Trace)
+	HandleFunc("/debug/goroutines", Goroutines)
 	HandleFunc("/log/level", logging.LevelHandler)
+	HandleFunc("POST /debug/trace/full", FullTrace)
 
 	s := &http.Server{
 		Addr:    Addr,
@@ -83,6 +89,48 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Goroutines writes a human-readable dump of all goroutine stacks. If the
+// "match" query parameter is set, only goroutines with a stack containing that
+// substring are included.
+func Goroutines(w http.ResponseWriter, r *http.Request) {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	match := r.URL.Query().Get("match")
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	// Goroutine stacks are separated by blank lines.
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if match == "" || strings.Contains(g, match) {
+			fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(g))
+		}
+	}
+}
+
+// FullTrace forces full tracing on for all new spans for the number of seconds
+// given by the "seconds" query parameter. See telemetry.ForceFullTrace.
+func FullTrace(w http.ResponseWriter, r *http.Request) {
+	seconds, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+	if err != nil || seconds <= 0 {
+		http.Error(w, "seconds must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	d := time.Duration(seconds) * time.Second
+	telemetry.ForceFullTrace(d)
+
+	logger.Sugar().Infow("full tracing enabled", "duration", d)
+	fmt.Fprintf(w, "full tracing enabled for %s\n", d)
+}
+
 func enabledMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if Enabled.Load() {
@@ -0,0 +1,82 @@
+package debug
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/replicate/go/telemetry"
+)
+
+func blockedInDistinctiveFunction(started chan struct{}, done chan struct{}, exited chan struct{}) {
+	close(started)
+	<-done
+	close(exited)
+}
+
+// startBlockedGoroutine starts a goroutine with a recognizable stack, which
+// exits when the test completes.
+func startBlockedGoroutine(t *testing.T) {
+	started := make(chan struct{})
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+		<-exited
+	})
+	go blockedInDistinctiveFunction(started, done, exited)
+	<-started
+}
+
+func TestGoroutines(t *testing.T) {
+	startBlockedGoroutine(t)
+
+	w := httptest.NewRecorder()
+	Goroutines(w, httptest.NewRequest("GET", "/debug/goroutines", nil))
+
+	body := w.Body.String()
+	assert.Contains(t, body, "goroutine ")
+	assert.Contains(t, body, "blockedInDistinctiveFunction")
+	assert.Contains(t, body, "TestGoroutines")
+}
+
+func TestGoroutinesMatch(t *testing.T) {
+	startBlockedGoroutine(t)
+
+	w := httptest.NewRecorder()
+	Goroutines(w, httptest.NewRequest("GET", "/debug/goroutines?match=blockedInDistinctiveFunction", nil))
+
+	body := w.Body.String()
+	assert.Contains(t, body, "blockedInDistinctiveFunction")
+	// The goroutine serving the request should have been filtered out.
+	assert.NotContains(t, body, "debug.Goroutines(")
+	for _, g := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		assert.Contains(t, g, "blockedInDistinctiveFunction")
+	}
+}
+
+func TestFullTrace(t *testing.T) {
+	t.Cleanup(func() { telemetry.ForceFullTrace(0) })
+
+	w := httptest.NewRecorder()
+	FullTrace(w, httptest.NewRequest("POST", "/debug/trace/full?seconds=30", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	to := telemetry.TraceOptionsFromContext(context.Background())
+	assert.Equal(t, telemetry.DetailLevelFull, to.DetailLevel)
+	assert.Equal(t, telemetry.SampleModeAlways, to.SampleMode)
+}
+
+func TestFullTraceInvalidSeconds(t *testing.T) {
+	for _, q := range []string{"", "?seconds=0", "?seconds=-5", "?seconds=banana"} {
+		w := httptest.NewRecorder()
+		FullTrace(w, httptest.NewRequest("POST", "/debug/trace/full"+q, nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, q)
+	}
+}
@@ -46,6 +46,17 @@ func FlagContextFromContext(ctx context.Context) ldcontext.Context {
 	return c
 }
 
+// EnsureContext returns a child context with the LaunchDarkly user context for
+// the given user ID and request (as built by GetUser) stored on it, so that it
+// can be retrieved by FlagContextFromContext. If a LaunchDarkly context has
+// already been stored on ctx, it is returned unchanged.
+func EnsureContext(ctx context.Context, id int, r *http.Request) context.Context {
+	if _, ok := ctx.Value(savedContextKey).(ldcontext.Context); ok {
+		return ctx
+	}
+	return WithFlagContext(ctx, GetUser(id, r))
+}
+
 func GetUser(id int, r *http.Request) ldcontext.Context {
 	if id == 0 {
 		return unknownUser
@@ -72,3 +83,14 @@ func GetVersion(id string) ldcontext.Context {
 	// etc.) in LaunchDarkly.
 	return ldcontext.NewWithKind(VersionKind, id)
 }
+
+// NewMultiContext combines contexts of different kinds (for example a user and
+// their organization) into a single multi-kind context, so that flag targeting
+// rules can reference attributes of any of them. The result can be passed to
+// any of the Flag functions.
+//
+// Each context must have a different kind. If not, the returned context will be
+// invalid, and flags evaluated against it will return their default values.
+func NewMultiContext(contexts ...ldcontext.Context) ldcontext.Context {
+	return ldcontext.NewMulti(contexts...)
+}
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -46,3 +48,67 @@ func TestFlagContextFromContextRetrievesUnknownUserIfNothingSaved(t *testing.T)
 
 	require.Equal(t, unknownUser, retrievedUser)
 }
+
+func TestEnsureContext(t *testing.T) {
+	r, _ := http.NewRequest("GET", "https://example.com", nil)
+	r.Header.Set("CF-Connecting-IP", "203.0.113.24")
+
+	ctx := EnsureContext(context.Background(), 12345, r)
+	user := FlagContextFromContext(ctx)
+
+	require.Equal(t, GetUser(12345, r), user)
+	require.Equal(t, "e05Y", user.Key())
+	require.Equal(t, "203.0.113.24", user.GetValue("ip").StringValue())
+}
+
+func TestEnsureContextPreservesExistingContext(t *testing.T) {
+	r, _ := http.NewRequest("GET", "https://example.com", nil)
+
+	testUser := ldcontext.NewBuilder("giraffe").Build()
+	ctx := WithFlagContext(context.Background(), testUser)
+	ctx = EnsureContext(ctx, 12345, r)
+
+	require.Equal(t, testUser, FlagContextFromContext(ctx))
+}
+
+func TestNewMultiContext(t *testing.T) {
+	user := ldcontext.New("e7")
+	org := ldcontext.NewWithKind("organization", "acme")
+
+	c := NewMultiContext(user, org)
+
+	require.NoError(t, c.Err())
+	assert.True(t, c.Multiple())
+	assert.Equal(t, "e7", c.IndividualContextByKind(ldcontext.DefaultKind).Key())
+	assert.Equal(t, "acme", c.IndividualContextByKind("organization").Key())
+}
+
+func TestNewMultiContextDuplicateKinds(t *testing.T) {
+	c := NewMultiContext(ldcontext.New("e7"), ldcontext.New("e05Y"))
+
+	assert.Error(t, c.Err())
+}
+
+func TestFlagMultiContextEvaluation(t *testing.T) {
+	td := useTestData(t)
+	td.Update(td.Flag("org-rollout").
+		BooleanFlag().
+		FallthroughVariation(false).
+		IfMatchContext("organization", "key", ldvalue.String("acme")).
+		AndMatchContext(ldcontext.DefaultKind, "key", ldvalue.String("e7")).
+		ThenReturn(true))
+
+	user := ldcontext.New("e7")
+	otherUser := ldcontext.New("e05Y")
+	org := ldcontext.NewWithKind("organization", "acme")
+	otherOrg := ldcontext.NewWithKind("organization", "initech")
+
+	both := NewMultiContext(user, org)
+	wrongUser := NewMultiContext(otherUser, org)
+	wrongOrg := NewMultiContext(user, otherOrg)
+
+	assert.True(t, Flag(&both, "org-rollout"))
+	assert.False(t, Flag(&wrongUser, "org-rollout"))
+	assert.False(t, Flag(&wrongOrg, "org-rollout"))
+	assert.False(t, Flag(&user, "org-rollout"))
+}
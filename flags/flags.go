@@ -1,11 +1,13 @@
 package flags
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
 	ld "github.com/launchdarkly/go-server-sdk/v6"
 
 	"github.com/replicate/go/logging"
@@ -25,9 +27,23 @@ const (
 	ResultYellow BlueYellowResult = "yellow"
 )
 
-func Init(key string) {
-	log := logger.Sugar()
+var (
+	// ErrNotInitialized is returned by Init if the LaunchDarkly client failed
+	// to initialize, for example because the SDK key is invalid.
+	ErrNotInitialized = errors.New("LaunchDarkly client failed to initialize")
+
+	// ErrNilContext is returned by FlagDetail if passed a nil context.
+	ErrNilContext = errors.New("flag evaluated with nil context")
+)
 
+// Init configures the LaunchDarkly client used to evaluate flags. If key is
+// empty, the client is configured in offline mode and all flags will return
+// their default values.
+//
+// If the client cannot be initialized, Init returns an error. Flags will still
+// return their default values in this case, so callers can decide whether it is
+// safe to proceed.
+func Init(key string) error {
 	config := ld.Config{
 		Logging: configureLogger(logger),
 	}
@@ -36,16 +52,29 @@ func Init(key string) {
 		config.Offline = true
 	}
 
-	client, err := ld.MakeCustomClient(key, config, 5*time.Second)
+	return initClient(key, config, 5*time.Second)
+}
+
+func initClient(key string, config ld.Config, waitFor time.Duration) error {
+	log := logger.Sugar()
+
+	client, err := ld.MakeCustomClient(key, config, waitFor)
+	// MakeCustomClient may return a client even if it failed to initialize. We
+	// keep it, as it may yet succeed in connecting, and in the meantime
+	// lookupDefault will return default values.
+	currentClient = client
+
 	if err != nil {
 		log.Warnw("failed to make LaunchDarkly client", "error", err)
+		return fmt.Errorf("%w: %w", ErrNotInitialized, err)
 	}
 
 	if !client.Initialized() {
 		log.Warn("failed to initialize LaunchDarkly client")
+		return ErrNotInitialized
 	}
 
-	currentClient = client
+	return nil
 }
 
 func Close() error {
@@ -59,6 +88,25 @@ func Flag(context *ldcontext.Context, name string) bool {
 	return lookupDefault(context, name, false)
 }
 
+// FlagDetail evaluates a boolean flag in the same way as Flag, but also returns
+// LaunchDarkly's explanation of how the value was determined (for example
+// RULE_MATCH or FALLTHROUGH), for use when debugging evaluations.
+//
+// Values set with Override are returned with an empty reason.
+func FlagDetail(context *ldcontext.Context, name string) (bool, ldreason.EvaluationReason, error) {
+	if result, ok := overrides[name]; ok {
+		return result, ldreason.EvaluationReason{}, nil
+	}
+	if currentClient == nil {
+		return false, ldreason.NewEvalReasonError(ldreason.EvalErrorClientNotReady), ErrNotInitialized
+	}
+	if context == nil {
+		return false, ldreason.NewEvalReasonError(ldreason.EvalErrorUserNotSpecified), ErrNilContext
+	}
+	result, detail, err := currentClient.BoolVariationDetail(name, *context, false)
+	return result, detail.Reason, err
+}
+
 // Override allows setting flag overrides. This is usually only used in the
 // context of testing.
 func Override(f func(map[string]bool)) {
@@ -145,6 +193,7 @@ func lookupDefault(context *ldcontext.Context, name string, defaultVal bool) boo
 		if _, loaded := emittedWarnings.LoadOrStore(w, true); !loaded {
 			log.Warn(w)
 		}
+		return defaultVal
 	}
 	return result
 }
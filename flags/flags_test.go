@@ -1,13 +1,38 @@
 package flags
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	ld "github.com/launchdarkly/go-server-sdk/v6"
+	"github.com/launchdarkly/go-server-sdk/v6/ldcomponents"
+	"github.com/launchdarkly/go-server-sdk/v6/testhelpers/ldtestdata"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// useTestData configures the flags client with a LaunchDarkly test data source
+// for the duration of the test.
+func useTestData(t *testing.T) *ldtestdata.TestDataSource {
+	t.Helper()
+
+	prev := currentClient
+	t.Cleanup(func() {
+		_ = Close()
+		currentClient = prev
+	})
+
+	td := ldtestdata.DataSource()
+	require.NoError(t, InitTestData(td))
+
+	return td
+}
+
 func TestFlagDefault(t *testing.T) {
 	testcontext := ldcontext.New("__test__")
 
@@ -60,3 +85,76 @@ func TestOverrides(t *testing.T) {
 	require.False(t, Flag(&testcontext, "myflag"))
 	require.True(t, KillSwitch(&testcontext, "otherflag"))
 }
+
+func TestInitOffline(t *testing.T) {
+	require.NoError(t, Init(""))
+}
+
+func TestInitInvalidKey(t *testing.T) {
+	prev := currentClient
+	t.Cleanup(func() {
+		_ = Close()
+		currentClient = prev
+	})
+
+	// Simulate LaunchDarkly rejecting the SDK key.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+
+	config := ld.Config{
+		Events:           ldcomponents.NoEvents(),
+		Logging:          ldcomponents.NoLogging(),
+		ServiceEndpoints: ldcomponents.RelayProxyEndpointsWithoutEvents(srv.URL),
+	}
+
+	err := initClient("bad-key", config, 500*time.Millisecond)
+	require.ErrorIs(t, err, ErrNotInitialized)
+
+	testcontext := ldcontext.New("__test__")
+
+	assert.False(t, Flag(&testcontext, "anyflag"))
+	assert.True(t, KillSwitch(&testcontext, "anyflag"))
+	assert.Equal(t, ResultBlue, FlagBlueYellow(&testcontext, "anyflag", ResultBlue))
+	assert.Equal(t, ResultYellow, FlagBlueYellow(&testcontext, "anyflag", ResultYellow))
+}
+
+func TestFlagDetailRuleMatch(t *testing.T) {
+	td := useTestData(t)
+	td.Update(td.Flag("myflag").
+		BooleanFlag().
+		FallthroughVariation(false).
+		IfMatch("key", ldvalue.String("giraffe")).
+		ThenReturn(true))
+
+	giraffe := ldcontext.New("giraffe")
+	result, reason, err := FlagDetail(&giraffe, "myflag")
+	require.NoError(t, err)
+	assert.True(t, result)
+	assert.Equal(t, ldreason.EvalReasonRuleMatch, reason.GetKind())
+	assert.Equal(t, 0, reason.GetRuleIndex())
+
+	zebra := ldcontext.New("zebra")
+	result, reason, err = FlagDetail(&zebra, "myflag")
+	require.NoError(t, err)
+	assert.False(t, result)
+	assert.Equal(t, ldreason.EvalReasonFallthrough, reason.GetKind())
+}
+
+func TestFlagDetailUnknownFlag(t *testing.T) {
+	useTestData(t)
+
+	testcontext := ldcontext.New("__test__")
+	result, reason, err := FlagDetail(&testcontext, "anyflag")
+	assert.Error(t, err)
+	assert.False(t, result)
+	assert.Equal(t, ldreason.EvalErrorFlagNotFound, reason.GetErrorKind())
+}
+
+func TestFlagDetailNilContext(t *testing.T) {
+	useTestData(t)
+
+	_, _, err := FlagDetail(nil, "anyflag")
+	assert.ErrorIs(t, err, ErrNilContext)
+}
@@ -0,0 +1,28 @@
+package flags
+
+import (
+	"hash/fnv"
+)
+
+// PercentRollout deterministically decides whether key falls within a rollout
+// to the given percentage of keys. The same key always receives the same
+// decision for a given percentage, and increasing the percentage never removes
+// a key from the rollout.
+//
+// Unlike the other functions in this package, PercentRollout does not depend on
+// LaunchDarkly, so it continues to work if LaunchDarkly is unavailable.
+//
+// Note that because the decision depends only on the key, a key that is
+// included in one rollout at a given percentage will be included in all of
+// them. Prefix the key with a rollout-specific name if that is undesirable.
+func PercentRollout(key string, percent int) bool {
+	if percent <= 0 {
+		return false
+	}
+	if percent >= 100 {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32()%100) < percent
+}
@@ -0,0 +1,54 @@
+package flags
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentRolloutBounds(t *testing.T) {
+	assert.False(t, PercentRollout("giraffe", 0))
+	assert.False(t, PercentRollout("giraffe", -10))
+	assert.True(t, PercentRollout("giraffe", 100))
+	assert.True(t, PercentRollout("giraffe", 150))
+}
+
+func TestPercentRolloutDeterministic(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		first := PercentRollout(key, 37)
+		for j := 0; j < 10; j++ {
+			assert.Equal(t, first, PercentRollout(key, 37), "key %s", key)
+		}
+	}
+}
+
+func TestPercentRolloutMonotonic(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		included := false
+		for percent := 0; percent <= 100; percent++ {
+			result := PercentRollout(key, percent)
+			if included {
+				assert.True(t, result, "key %s dropped out of rollout at %d%%", key, percent)
+			}
+			included = result
+		}
+	}
+}
+
+func TestPercentRolloutDistribution(t *testing.T) {
+	const n = 10000
+
+	for _, percent := range []int{1, 10, 25, 50, 90} {
+		count := 0
+		for i := 0; i < n; i++ {
+			if PercentRollout(fmt.Sprintf("user:%d", i), percent) {
+				count++
+			}
+		}
+		expected := n * percent / 100
+		assert.InDelta(t, expected, count, n*0.02, "percent=%d", percent)
+	}
+}
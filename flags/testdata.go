@@ -0,0 +1,33 @@
+package flags
+
+import (
+	"time"
+
+	ld "github.com/launchdarkly/go-server-sdk/v6"
+	"github.com/launchdarkly/go-server-sdk/v6/ldcomponents"
+	"github.com/launchdarkly/go-server-sdk/v6/testhelpers/ldtestdata"
+)
+
+// InitTestData configures the flags client to evaluate flags against the passed
+// LaunchDarkly test data source rather than LaunchDarkly itself. Unlike
+// Override, this exercises real flag evaluation, so tests can define targeting
+// rules and rollouts and have them applied to contexts built with GetUser,
+// GetVersion, etc:
+//
+//	td := ldtestdata.DataSource()
+//	td.Update(td.Flag("my-flag").BooleanFlag().VariationForUser(key, true))
+//	if err := flags.InitTestData(td); err != nil {
+//	  ...
+//	}
+//	defer flags.Close()
+//
+// Flags can be updated with td.Update at any time after initialization.
+func InitTestData(td *ldtestdata.TestDataSource) error {
+	config := ld.Config{
+		DataSource: td,
+		Events:     ldcomponents.NoEvents(),
+		Logging:    configureLogger(logger),
+	}
+
+	return initClient("test-data", config, 5*time.Second)
+}
@@ -0,0 +1,48 @@
+package flags
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitTestDataTargetsUser(t *testing.T) {
+	r, _ := http.NewRequest("GET", "https://example.com", nil)
+	giraffe := GetUser(12345, r)
+	zebra := GetUser(36395, r)
+
+	td := useTestData(t)
+	td.Update(td.Flag("myflag").
+		BooleanFlag().
+		FallthroughVariation(false).
+		VariationForUser(giraffe.Key(), true))
+
+	assert.True(t, Flag(&giraffe, "myflag"))
+	assert.False(t, Flag(&zebra, "myflag"))
+}
+
+func TestInitTestDataTargetsVersion(t *testing.T) {
+	version := GetVersion("abc123")
+	otherVersion := GetVersion("def456")
+
+	td := useTestData(t)
+	td.Update(td.Flag("myflag").
+		BooleanFlag().
+		FallthroughVariation(false).
+		VariationForKey(VersionKind, version.Key(), true))
+
+	assert.True(t, Flag(&version, "myflag"))
+	assert.False(t, Flag(&otherVersion, "myflag"))
+}
+
+func TestInitTestDataUpdates(t *testing.T) {
+	testcontext := GetVersion("abc123")
+
+	td := useTestData(t)
+	td.Update(td.Flag("myflag").BooleanFlag().VariationForAll(false))
+	assert.False(t, Flag(&testcontext, "myflag"))
+
+	td.Update(td.Flag("myflag").BooleanFlag().VariationForAll(true))
+	assert.True(t, Flag(&testcontext, "myflag"))
+}
@@ -20,33 +20,36 @@ const ConnectTimeout = 5 * time.Second
 
 // DefaultRoundTripper returns an http.RoundTripper with similar default values
 // to http.DefaultTransport, but with idle connections and keepalives disabled.
-// The transport is configured to emit OTel spans.
-func DefaultRoundTripper() http.RoundTripper {
+// The transport is configured to emit OTel spans unless WithoutTracing is
+// passed.
+func DefaultRoundTripper(options ...Option) http.RoundTripper {
 	transport := DefaultPooledTransport()
 	transport.DisableKeepAlives = true
 	transport.MaxIdleConnsPerHost = -1
-	return otelhttp.NewTransport(transport)
+	return wrap(transport, options)
 }
 
 // DefaultPooledRoundTripper returns an http.RoundTripper with similar default
 // values to http.DefaultTransport. Do not use this for transient transports as
 // it can leak file descriptors over time. Only use this for transports that
 // will be re-used for the same host(s).
-func DefaultPooledRoundTripper() http.RoundTripper {
-	return otelhttp.NewTransport(DefaultPooledTransport())
+func DefaultPooledRoundTripper(options ...Option) http.RoundTripper {
+	return wrap(DefaultPooledTransport(), options)
 }
 
 // PooledEgressRoundTripper returns an http.RoundTripper designed to call
 // arbitrary 3rd-party endpoints. It accepts a proxy function which in
-// production should point to a suitable egress proxy.
-func PooledEgressRoundTripper(proxy func(*http.Request) (*url.URL, error)) http.RoundTripper {
+// production should point to a suitable egress proxy. Like the other round
+// trippers it emits OTel spans unless WithoutTracing is passed, but it never
+// forwards trace context to the remote endpoint.
+func PooledEgressRoundTripper(proxy func(*http.Request) (*url.URL, error), options ...Option) http.RoundTripper {
 	transport := DefaultPooledTransport()
 	transport.Proxy = proxy
 
 	// Set a no-op propagator that won't forward any trace info.
 	noopPropagator := propagation.NewCompositeTextMapPropagator()
 
-	return otelhttp.NewTransport(transport, otelhttp.WithPropagators(noopPropagator))
+	return wrap(transport, options, otelhttp.WithPropagators(noopPropagator))
 }
 
 // DefaultPooledTransport returns a new http.Transport with similar default
@@ -80,9 +83,9 @@ func DefaultPooledTransport() *http.Transport {
 // DefaultClient returns a new http.Client with similar default values to
 // http.Client, but with a non-shared Transport, idle connections disabled, and
 // keepalives disabled.
-func DefaultClient() *http.Client {
+func DefaultClient(options ...Option) *http.Client {
 	return &http.Client{
-		Transport: DefaultRoundTripper(),
+		Transport: DefaultRoundTripper(options...),
 	}
 }
 
@@ -90,12 +93,25 @@ func DefaultClient() *http.Client {
 // http.Client, but with a shared Transport. Do not use this function for
 // transient clients as it can leak file descriptors over time. Only use this
 // for clients that will be re-used for the same host(s).
-func DefaultPooledClient() *http.Client {
+func DefaultPooledClient(options ...Option) *http.Client {
 	return &http.Client{
-		Transport: DefaultPooledRoundTripper(),
+		Transport: DefaultPooledRoundTripper(options...),
 	}
 }
 
+// wrap wraps transport in an OTel transport configured with otelOpts, unless
+// tracing has been disabled.
+func wrap(transport *http.Transport, options []Option, otelOpts ...otelhttp.Option) http.RoundTripper {
+	var opts clientOptions
+	for _, o := range options {
+		o.apply(&opts)
+	}
+	if opts.WithoutTracing {
+		return transport
+	}
+	return otelhttp.NewTransport(transport, otelOpts...)
+}
+
 func configureHTTP2(t *http.Transport) {
 	h2t, err := http2.ConfigureTransports(t)
 	if err != nil {
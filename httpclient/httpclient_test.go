@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func useSpanRecorder(t testing.TB) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	orig := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(orig) })
+
+	return sr
+}
+
+func newTestServer(t testing.TB) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t testing.TB, client *http.Client, url string) {
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestDefaultPooledClientTracing(t *testing.T) {
+	sr := useSpanRecorder(t)
+	srv := newTestServer(t)
+
+	get(t, DefaultPooledClient(), srv.URL)
+
+	assert.Len(t, sr.Ended(), 1)
+}
+
+func TestDefaultPooledClientWithoutTracing(t *testing.T) {
+	sr := useSpanRecorder(t)
+	srv := newTestServer(t)
+
+	get(t, DefaultPooledClient(WithoutTracing()), srv.URL)
+	get(t, DefaultClient(WithoutTracing()), srv.URL)
+
+	assert.Empty(t, sr.Ended())
+}
+
+func TestPooledEgressRoundTripper(t *testing.T) {
+	sr := useSpanRecorder(t)
+
+	orig := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(orig) })
+
+	var traceparent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = append(traceparent, r.Header.Get("traceparent"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	get(t, &http.Client{Transport: PooledEgressRoundTripper(nil)}, srv.URL)
+	assert.Len(t, sr.Ended(), 1)
+
+	get(t, &http.Client{Transport: PooledEgressRoundTripper(nil, WithoutTracing())}, srv.URL)
+	assert.Len(t, sr.Ended(), 1)
+
+	// Trace context is never forwarded to 3rd-party endpoints
+	assert.Equal(t, []string{"", ""}, traceparent)
+}
+
+func BenchmarkRoundTrip(b *testing.B) {
+	useSpanRecorder(b)
+	srv := newTestServer(b)
+
+	b.Run("Traced", func(b *testing.B) {
+		client := DefaultPooledClient()
+		for range b.N {
+			get(b, client, srv.URL)
+		}
+	})
+	b.Run("Untraced", func(b *testing.B) {
+		client := DefaultPooledClient(WithoutTracing())
+		for range b.N {
+			get(b, client, srv.URL)
+		}
+	})
+}
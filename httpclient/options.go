@@ -0,0 +1,24 @@
+package httpclient
+
+type Option interface {
+	apply(*clientOptions)
+}
+
+type clientOptions struct {
+	WithoutTracing bool
+}
+
+type optionFunc func(*clientOptions)
+
+func (fn optionFunc) apply(opts *clientOptions) {
+	fn(opts)
+}
+
+// WithoutTracing configures the client or transport not to be wrapped in an
+// OTel transport, avoiding its per-request overhead. This is intended for very
+// high-volume internal calls where tracing is handled elsewhere.
+func WithoutTracing() Option {
+	return optionFunc(func(opts *clientOptions) {
+		opts.WithoutTracing = true
+	})
+}
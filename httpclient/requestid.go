@@ -0,0 +1,61 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/replicate/go/logging"
+	"github.com/replicate/go/uuid"
+)
+
+// RequestIDHeader is the header used to propagate request IDs.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID stored in ctx by
+// RequestIDRoundTripper, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// RequestIDRoundTripper wraps next with a transport that ensures every outgoing
+// request has an X-Request-ID header, generating a UUIDv7 if one is not already
+// set. The request ID is stored on the context of the request passed to next,
+// both so it can be retrieved with RequestIDFromContext and as a "request_id"
+// logging field, for correlation of any logs emitted while handling it.
+func RequestIDRoundTripper(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		id := req.Header.Get(RequestIDHeader)
+		if id == "" {
+			u, err := uuid.NewV7()
+			if err != nil {
+				// RoundTrippers must always close the request body, even on error.
+				if req.Body != nil {
+					_ = req.Body.Close()
+				}
+				return nil, fmt.Errorf("failed to generate request ID: %w", err)
+			}
+			id = u.String()
+		}
+
+		ctx := context.WithValue(req.Context(), requestIDKey{}, id)
+		ctx = logging.AddFields(ctx, zap.String("request_id", id))
+
+		// RoundTrippers must not modify the request they are given.
+		req = req.Clone(ctx)
+		req.Header.Set(RequestIDHeader, id)
+
+		return next.RoundTrip(req)
+	})
+}
@@ -0,0 +1,75 @@
+package httpclient
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/replicate/go/logging"
+	"github.com/replicate/go/uuid"
+)
+
+func captureRequest(req **http.Request) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		*req = r
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+	})
+}
+
+func TestRequestIDRoundTripperSetsHeader(t *testing.T) {
+	var got *http.Request
+	rt := RequestIDRoundTripper(captureRequest(&got))
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	id := got.Header.Get(RequestIDHeader)
+	require.NotEmpty(t, id)
+	assert.Len(t, id, 36)
+	assert.EqualValues(t, uuid.V7, id[14]-'0')
+
+	// The original request must not be modified
+	assert.Empty(t, req.Header.Get(RequestIDHeader))
+}
+
+func TestRequestIDRoundTripperReusesExistingHeader(t *testing.T) {
+	var got *http.Request
+	rt := RequestIDRoundTripper(captureRequest(&got))
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set(RequestIDHeader, "abc123")
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "abc123", got.Header.Get(RequestIDHeader))
+}
+
+func TestRequestIDRoundTripperStoresIDInContext(t *testing.T) {
+	var got *http.Request
+	rt := RequestIDRoundTripper(captureRequest(&got))
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	id := got.Header.Get(RequestIDHeader)
+
+	ctxID, ok := RequestIDFromContext(got.Context())
+	require.True(t, ok)
+	assert.Equal(t, id, ctxID)
+
+	assert.Contains(t, logging.GetFields(got.Context()), zap.String("request_id", id))
+}
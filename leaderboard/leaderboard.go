@@ -0,0 +1,92 @@
+// Package leaderboard implements a scored set of members backed by a Redis
+// sorted set, supporting top-N and rank queries.
+//
+// Each leaderboard is stored in a single key, "leaderboard:<name>", meaning that
+// this package should work without modification in a Redis cluster environment.
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrInvalidArgs = errors.New("leaderboard: invalid arguments")
+	ErrNotFound    = errors.New("leaderboard: member not found")
+)
+
+type Leaderboard struct {
+	rdb  redis.Cmdable
+	key  string
+	opts leaderboardOptions
+}
+
+// Entry is a member of a leaderboard and its score.
+type Entry struct {
+	Member string
+	Score  float64
+}
+
+func NewLeaderboard(rdb redis.Cmdable, name string, options ...Option) *Leaderboard {
+	l := &Leaderboard{
+		rdb: rdb,
+		key: "leaderboard:" + name,
+	}
+	for _, o := range options {
+		o.apply(&l.opts)
+	}
+	return l
+}
+
+// Add sets the score of member, adding it to the leaderboard if it is not
+// already present. If the leaderboard was configured WithTTL, its expiry is
+// reset.
+func (l *Leaderboard) Add(ctx context.Context, member string, score float64) error {
+	if l.opts.TTL == 0 {
+		return l.rdb.ZAdd(ctx, l.key, redis.Z{Score: score, Member: member}).Err()
+	}
+
+	pipe := l.rdb.TxPipeline()
+	pipe.ZAdd(ctx, l.key, redis.Z{Score: score, Member: member})
+	pipe.Expire(ctx, l.key, l.opts.TTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Top returns up to n members with the highest scores, highest first. Members
+// with equal scores are ordered lexicographically, in reverse.
+func (l *Leaderboard) Top(ctx context.Context, n int) ([]Entry, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: n must be > 0", ErrInvalidArgs)
+	}
+
+	zs, err := l.rdb.ZRevRangeWithScores(ctx, l.key, 0, int64(n-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(zs))
+	for i, z := range zs {
+		member, ok := z.Member.(string)
+		if !ok {
+			return nil, fmt.Errorf("leaderboard: unexpected member type %T", z.Member)
+		}
+		entries[i] = Entry{Member: member, Score: z.Score}
+	}
+	return entries, nil
+}
+
+// Rank returns the zero-based rank of member, where the member with the highest
+// score has rank 0. If member is not in the leaderboard, err will be
+// ErrNotFound.
+func (l *Leaderboard) Rank(ctx context.Context, member string) (int64, error) {
+	rank, err := l.rdb.ZRevRank(ctx, l.key, member).Result()
+	if err == redis.Nil {
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, member)
+	} else if err != nil {
+		return 0, err
+	}
+	return rank, nil
+}
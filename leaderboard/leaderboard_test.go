@@ -0,0 +1,128 @@
+package leaderboard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/replicate/go/test"
+)
+
+func TestLeaderboardIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	l := NewLeaderboard(rdb, "tenants", WithTTL(time.Minute))
+
+	require.NoError(t, l.Add(ctx, "giraffe", 3))
+	require.NoError(t, l.Add(ctx, "panda", 10))
+	require.NoError(t, l.Add(ctx, "elephant", 7))
+	require.NoError(t, l.Add(ctx, "zebra", 1))
+	require.NoError(t, l.Add(ctx, "giraffe", 8))
+
+	top, err := l.Top(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{Member: "panda", Score: 10},
+		{Member: "giraffe", Score: 8},
+		{Member: "elephant", Score: 7},
+	}, top)
+
+	top, err = l.Top(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, top, 4)
+
+	rank, err := l.Rank(ctx, "zebra")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, rank)
+
+	_, err = l.Rank(ctx, "tuna")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	ttl, err := rdb.TTL(ctx, "leaderboard:tenants").Result()
+	require.NoError(t, err)
+	assert.Greater(t, ttl, time.Duration(0))
+}
+
+func TestLeaderboardAdd(t *testing.T) {
+	ctx := test.Context(t)
+	rdb, mock := redismock.NewClientMock()
+
+	l := NewLeaderboard(rdb, "tenants")
+
+	mock.ExpectZAdd("leaderboard:tenants", redis.Z{Score: 4.5, Member: "panda"}).SetVal(1)
+
+	require.NoError(t, l.Add(ctx, "panda", 4.5))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeaderboardAddWithTTL(t *testing.T) {
+	ctx := test.Context(t)
+	rdb, mock := redismock.NewClientMock()
+
+	l := NewLeaderboard(rdb, "tenants", WithTTL(time.Hour))
+
+	mock.ExpectTxPipeline()
+	mock.ExpectZAdd("leaderboard:tenants", redis.Z{Score: 4.5, Member: "panda"}).SetVal(1)
+	mock.ExpectExpire("leaderboard:tenants", time.Hour).SetVal(true)
+	mock.ExpectTxPipelineExec()
+
+	require.NoError(t, l.Add(ctx, "panda", 4.5))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeaderboardTop(t *testing.T) {
+	ctx := test.Context(t)
+	rdb, mock := redismock.NewClientMock()
+
+	l := NewLeaderboard(rdb, "tenants")
+
+	mock.ExpectZRevRangeWithScores("leaderboard:tenants", 0, 1).SetVal([]redis.Z{
+		{Score: 10, Member: "panda"},
+		{Score: 8, Member: "giraffe"},
+	})
+
+	top, err := l.Top(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{Member: "panda", Score: 10},
+		{Member: "giraffe", Score: 8},
+	}, top)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = l.Top(ctx, 0)
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+}
+
+func TestLeaderboardRank(t *testing.T) {
+	ctx := test.Context(t)
+	rdb, mock := redismock.NewClientMock()
+
+	l := NewLeaderboard(rdb, "tenants")
+
+	mock.ExpectZRevRank("leaderboard:tenants", "panda").SetVal(2)
+	mock.ExpectZRevRank("leaderboard:tenants", "tuna").RedisNil()
+	mock.ExpectZRevRank("leaderboard:tenants", "zebra").SetErr(errors.New("boom"))
+
+	rank, err := l.Rank(ctx, "panda")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, rank)
+
+	_, err = l.Rank(ctx, "tuna")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = l.Rank(ctx, "zebra")
+	assert.EqualError(t, err, "boom")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTTLValidatesDuration(t *testing.T) {
+	assert.Panics(t, func() { WithTTL(0) })
+	assert.Panics(t, func() { WithTTL(-time.Second) })
+}
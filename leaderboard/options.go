@@ -0,0 +1,32 @@
+package leaderboard
+
+import (
+	"fmt"
+	"time"
+)
+
+type Option interface {
+	apply(*leaderboardOptions)
+}
+
+type leaderboardOptions struct {
+	TTL time.Duration
+}
+
+type optionFunc func(*leaderboardOptions)
+
+func (fn optionFunc) apply(opts *leaderboardOptions) {
+	fn(opts)
+}
+
+// WithTTL configures the leaderboard to expire after ttl. The expiry is reset
+// each time a member is added, so a leaderboard which is no longer updated is
+// eventually removed. It panics if ttl is not positive.
+func WithTTL(ttl time.Duration) Option {
+	if ttl <= 0 {
+		panic(fmt.Sprintf("leaderboard: TTL must be positive, got %v", ttl))
+	}
+	return optionFunc(func(opts *leaderboardOptions) {
+		opts.TTL = ttl
+	})
+}
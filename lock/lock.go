@@ -16,10 +16,17 @@ package lock
 import (
 	"context"
 	"errors"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/segmentio/ksuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/replicate/go/must"
+	"github.com/replicate/go/telemetry"
 )
 
 const retryInterval = 50 * time.Millisecond
@@ -29,9 +36,22 @@ var releaseScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] th
 var ErrLockNotAcquired = errors.New("locker: did not acquire lock")
 var ErrLockNotHeld = errors.New("locker: lock was not held")
 
+var (
+	meter = telemetry.Meter("go", "lock")
+
+	held = must.Get(meter.Int64UpDownCounter(
+		"lock.held",
+		metric.WithDescription("Number of locks currently held by this process"),
+	))
+)
+
 type Locker struct {
 	Clients []redis.Cmdable
 
+	// If Name is set, the number of locks currently held through this Locker is
+	// recorded in the lock.held metric, labeled with the name.
+	Name string
+
 	tokenGenerator func() string // test seam
 }
 
@@ -43,6 +63,13 @@ type lock struct {
 	clients []redis.Cmdable
 	key     string
 	token   string
+
+	name     string // for metrics, see Locker.Name
+	released sync.Once
+}
+
+type multiLock struct {
+	locks []Lock
 }
 
 // Prepare preloads any Lua scripts needed by locker. This allows later commands
@@ -58,6 +85,22 @@ func (l Locker) Prepare(ctx context.Context) error {
 	return nil
 }
 
+// ScriptsLoaded reports whether the locker's script is present in the Redis
+// script cache, under the key "release". With multiple clients, the script is
+// only reported as loaded if it is loaded on all of them. This can be used in
+// health checks to detect a flushed script cache (e.g. after a Redis restart).
+func (l Locker) ScriptsLoaded(ctx context.Context) (map[string]bool, error) {
+	loaded := true
+	for _, client := range l.Clients {
+		exists, err := client.ScriptExists(ctx, releaseScript.Hash()).Result()
+		if err != nil {
+			return nil, err
+		}
+		loaded = loaded && exists[0]
+	}
+	return map[string]bool{"release": loaded}, nil
+}
+
 // Acquire will attempt to acquire a lock at the specified key in Redis for the
 // given duration. If it fails to acquire the lock because someone else is
 // already holding it, it will retry until the passed context is canceled. If
@@ -100,6 +143,7 @@ func (l Locker) TryAcquire(ctx context.Context, key string, ttl time.Duration) (
 		clients: l.Clients,
 		key:     key,
 		token:   token,
+		name:    l.Name,
 	}
 	for i, client := range l.Clients {
 		ok, err := client.SetNX(ctx, key, token, ttl).Result()
@@ -113,17 +157,140 @@ func (l Locker) TryAcquire(ctx context.Context, key string, ttl time.Duration) (
 		}
 	}
 
+	if ret.name != "" {
+		held.Add(ctx, 1, metric.WithAttributes(attribute.String("lock.name", ret.name)))
+	}
+	return &ret, nil
+}
+
+// AcquireMulti acquires locks at all of the specified keys, as Acquire, and
+// returns a single Lock which releases all of them. Keys are acquired in sorted
+// order (and released in the reverse order) so that callers locking
+// overlapping sets of keys cannot deadlock. Duplicate keys are locked once.
+//
+// If any of the locks cannot be acquired, those already acquired are released
+// before returning the error.
+//
+// As with Acquire, the caller must control the blocking time by passing in a
+// context that is cancelable or which has a deadline.
+func (l Locker) AcquireMulti(ctx context.Context, keys []string, ttl time.Duration) (Lock, error) {
+	sorted := slices.Clone(keys)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	ret := multiLock{locks: make([]Lock, 0, len(sorted))}
+	for _, key := range sorted {
+		lock, err := l.Acquire(ctx, key, ttl)
+		if err != nil {
+			// The most likely reason for failure is that ctx is done, so don't
+			// let that prevent us from rolling back.
+			releaseErr := ret.Release(context.WithoutCancel(ctx))
+			return nil, errors.Join(err, releaseErr)
+		}
+		ret.locks = append(ret.locks, lock)
+	}
+
 	return &ret, nil
 }
 
+// Owner describes the holder of a lock in a single Redis instance, as returned
+// by Locker.Owners.
+type Owner struct {
+	// Token identifies the holder of the lock. It is empty if the lock is not
+	// held.
+	Token string
+	// TTL is the time remaining until the lock expires.
+	TTL time.Duration
+}
+
+// Owner returns the token of the current holder of the lock at key, and the
+// time remaining until it expires, for use when debugging lock contention. If
+// the lock is not held it returns ErrLockNotHeld.
+//
+// If the Locker has multiple clients, Owner reports the holder in the first,
+// which is always acquired first. Use Owners to inspect all of them.
+func (l Locker) Owner(ctx context.Context, key string) (token string, ttl time.Duration, err error) {
+	if len(l.Clients) == 0 {
+		return "", 0, ErrLockNotHeld
+	}
+	o, err := owner(ctx, l.Clients[0], key)
+	if err != nil {
+		return "", 0, err
+	}
+	if o.Token == "" {
+		return "", 0, ErrLockNotHeld
+	}
+	return o.Token, o.TTL, nil
+}
+
+// Owners returns the holder of the lock at key in each of the Locker's clients,
+// in the same order as Clients. Where the lock is not held, the corresponding
+// Owner will have an empty Token.
+func (l Locker) Owners(ctx context.Context, key string) ([]Owner, error) {
+	owners := make([]Owner, len(l.Clients))
+	for i, client := range l.Clients {
+		o, err := owner(ctx, client, key)
+		if err != nil {
+			return nil, err
+		}
+		owners[i] = o
+	}
+	return owners, nil
+}
+
+func owner(ctx context.Context, client redis.Cmdable, key string) (Owner, error) {
+	var (
+		get  *redis.StringCmd
+		pttl *redis.DurationCmd
+	)
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return Owner{}, nil
+	}
+	if err != nil {
+		return Owner{}, err
+	}
+
+	ttl := pttl.Val()
+	if ttl < 0 {
+		// The key has no expiry, so it was not set by TryAcquire.
+		ttl = 0
+	}
+	return Owner{Token: get.Val(), TTL: ttl}, nil
+}
+
 // Release attempts to release the lock in Redis. If the lock has already
 // expired, or if the lock is held by another party, it will return
 // ErrLockNotHeld. It may also return errors if it cannot communicate with
 // Redis.
 func (l *lock) Release(ctx context.Context) error {
+	if l.name != "" {
+		// Even if releasing fails, the lock is no longer held by us, either
+		// because it has expired or because it will shortly.
+		l.released.Do(func() {
+			held.Add(ctx, -1, metric.WithAttributes(attribute.String("lock.name", l.name)))
+		})
+	}
 	return l.release(ctx, len(l.clients))
 }
 
+// Release releases all the locks, in the opposite order from acquiring them.
+// It attempts to release every lock even if releasing some of them fails, and
+// returns all the errors encountered.
+func (l *multiLock) Release(ctx context.Context) error {
+	errs := []error{}
+	for i := len(l.locks) - 1; i >= 0; i-- {
+		if err := l.locks[i].Release(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (l *lock) release(ctx context.Context, n int) error {
 	errs := []error{}
 
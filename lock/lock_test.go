@@ -12,6 +12,8 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 
 	"github.com/replicate/go/test"
 )
@@ -411,3 +413,220 @@ func TestLockTryAcquireIntegration(t *testing.T) {
 	// Check that only one goroutine got the lock
 	require.Equal(t, 1, len(results))
 }
+
+func TestLockerOwner(t *testing.T) {
+	ctx := test.Context(t)
+	mr, rdb := test.MiniRedis(t)
+	locker := Locker{
+		Clients:        []redis.Cmdable{rdb},
+		tokenGenerator: func() string { return "giraffe" },
+	}
+
+	_, err := locker.TryAcquire(ctx, "somekey", 10*time.Second)
+	require.NoError(t, err)
+
+	mr.FastForward(3 * time.Second)
+
+	token, ttl, err := locker.Owner(ctx, "somekey")
+	require.NoError(t, err)
+	assert.Equal(t, "giraffe", token)
+	assert.Equal(t, 7*time.Second, ttl)
+}
+
+func TestLockerOwnerReturnsErrLockNotHeldWhenUnset(t *testing.T) {
+	ctx := test.Context(t)
+	_, rdb := test.MiniRedis(t)
+	locker := Locker{Clients: []redis.Cmdable{rdb}}
+
+	_, _, err := locker.Owner(ctx, "somekey")
+	assert.ErrorIs(t, err, ErrLockNotHeld)
+}
+
+func TestLockerOwnerReturnsErrLockNotHeldAfterRelease(t *testing.T) {
+	ctx := test.Context(t)
+	_, rdb := test.MiniRedis(t)
+	locker := Locker{Clients: []redis.Cmdable{rdb}}
+
+	l, err := locker.TryAcquire(ctx, "somekey", 10*time.Second)
+	require.NoError(t, err)
+	require.NoError(t, l.Release(ctx))
+
+	_, _, err = locker.Owner(ctx, "somekey")
+	assert.ErrorIs(t, err, ErrLockNotHeld)
+}
+
+func TestLockerOwnersReportsPerClient(t *testing.T) {
+	ctx := test.Context(t)
+	_, rdb1 := test.MiniRedis(t)
+	_, rdb2 := test.MiniRedis(t)
+	locker := Locker{Clients: []redis.Cmdable{rdb1, rdb2}}
+
+	// Only the second instance has the lock set, for example because it was
+	// acquired by a client which only knows about that instance.
+	require.NoError(t, rdb2.Set(ctx, "somekey", "zebra", 5*time.Second).Err())
+
+	owners, err := locker.Owners(ctx, "somekey")
+	require.NoError(t, err)
+	assert.Equal(t, []Owner{
+		{},
+		{Token: "zebra", TTL: 5 * time.Second},
+	}, owners)
+
+	_, _, err = locker.Owner(ctx, "somekey")
+	assert.ErrorIs(t, err, ErrLockNotHeld)
+}
+
+func TestLockerAcquireMultiAcquiresInSortedOrderAndReleasesInReverse(t *testing.T) {
+	ctx := context.Background()
+	client, mock := redismock.NewClientMock()
+	locker := Locker{
+		Clients:        []redis.Cmdable{client},
+		tokenGenerator: func() string { return "okapi" },
+	}
+
+	mock.Regexp().ExpectScriptLoad(`if redis.call\("get", KEYS\[1\]\) .+`).SetVal(releaseScript.Hash())
+	mock.ExpectSetNX("a", "okapi", 1*time.Second).SetVal(true)
+	mock.ExpectSetNX("b", "okapi", 1*time.Second).SetVal(true)
+	mock.ExpectSetNX("c", "okapi", 1*time.Second).SetVal(true)
+	mock.ExpectEvalSha(releaseScript.Hash(), []string{"c"}, "okapi").SetVal(int64(1))
+	mock.ExpectEvalSha(releaseScript.Hash(), []string{"b"}, "okapi").SetVal(int64(1))
+	mock.ExpectEvalSha(releaseScript.Hash(), []string{"a"}, "okapi").SetVal(int64(1))
+
+	err := locker.Prepare(ctx)
+	assert.NoError(t, err)
+
+	l, err := locker.AcquireMulti(ctx, []string{"c", "a", "b", "a"}, 1*time.Second)
+
+	require.NoError(t, err)
+	require.NotNil(t, l)
+
+	err = l.Release(ctx)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLockerAcquireMultiReleasesAcquiredLocksOnFailure(t *testing.T) {
+	ctx := context.Background()
+	client, mock := redismock.NewClientMock()
+	locker := Locker{
+		Clients:        []redis.Cmdable{client},
+		tokenGenerator: func() string { return "tapir" },
+	}
+
+	mock.Regexp().ExpectScriptLoad(`if redis.call\("get", KEYS\[1\]\) .+`).SetVal(releaseScript.Hash())
+	mock.ExpectSetNX("a", "tapir", 1*time.Second).SetVal(true)
+	mock.ExpectSetNX("b", "tapir", 1*time.Second).SetVal(true)
+	mock.ExpectSetNX("c", "tapir", 1*time.Second).SetErr(fmt.Errorf("connection refused"))
+	mock.ExpectEvalSha(releaseScript.Hash(), []string{"b"}, "tapir").SetVal(int64(1))
+	mock.ExpectEvalSha(releaseScript.Hash(), []string{"a"}, "tapir").SetVal(int64(1))
+
+	err := locker.Prepare(ctx)
+	assert.NoError(t, err)
+
+	l, err := locker.AcquireMulti(ctx, []string{"b", "c", "a"}, 1*time.Second)
+
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Nil(t, l)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLockerAcquireMultiIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+	locker := Locker{Clients: []redis.Cmdable{rdb}}
+
+	held, err := locker.TryAcquire(ctx, "b", 10*time.Second)
+	require.NoError(t, err)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	_, err = locker.AcquireMulti(ctxTimeout, []string{"c", "b", "a"}, 10*time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The lock on "a" must have been rolled back, and "c" never acquired.
+	n, err := rdb.Exists(ctx, "a", "c").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+
+	require.NoError(t, held.Release(ctx))
+
+	l, err := locker.AcquireMulti(ctx, []string{"c", "b", "a"}, 10*time.Second)
+	require.NoError(t, err)
+	n, err = rdb.Exists(ctx, "a", "b", "c").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+
+	require.NoError(t, l.Release(ctx))
+	n, err = rdb.Exists(ctx, "a", "b", "c").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+}
+
+func TestLockerHeldMetric(t *testing.T) {
+	ctx := test.Context(t)
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	orig := held
+	c, err := mp.Meter("test").Int64UpDownCounter("lock.held")
+	require.NoError(t, err)
+	held = c
+	t.Cleanup(func() { held = orig })
+
+	heldCount := func() int64 {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(ctx, &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+		require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+		sum, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+		require.True(t, ok)
+		require.Len(t, sum.DataPoints, 1)
+		name, _ := sum.DataPoints[0].Attributes.Value("lock.name")
+		assert.Equal(t, "widgets", name.AsString())
+		return sum.DataPoints[0].Value
+	}
+
+	_, rdb := test.MiniRedis(t)
+	locker := Locker{Clients: []redis.Cmdable{rdb}, Name: "widgets"}
+
+	l1, err := locker.TryAcquire(ctx, "a", 10*time.Second)
+	require.NoError(t, err)
+	l2, err := locker.TryAcquire(ctx, "b", 10*time.Second)
+	require.NoError(t, err)
+	_, err = locker.TryAcquire(ctx, "a", 10*time.Second)
+	require.ErrorIs(t, err, ErrLockNotAcquired)
+	assert.EqualValues(t, 2, heldCount())
+
+	require.NoError(t, l1.Release(ctx))
+	assert.EqualValues(t, 1, heldCount())
+
+	require.NoError(t, l2.Release(ctx))
+	// Releasing again fails, but doesn't decrement the count again
+	require.ErrorIs(t, l2.Release(ctx), ErrLockNotHeld)
+	assert.EqualValues(t, 0, heldCount())
+}
+
+func TestLockerScriptsLoaded(t *testing.T) {
+	ctx := test.Context(t)
+	_, rdb1 := test.MiniRedis(t)
+	_, rdb2 := test.MiniRedis(t)
+	locker := Locker{Clients: []redis.Cmdable{rdb1, rdb2}}
+
+	loaded, err := locker.ScriptsLoaded(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"release": false}, loaded)
+
+	require.NoError(t, locker.Prepare(ctx))
+	loaded, err = locker.ScriptsLoaded(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"release": true}, loaded)
+
+	// The script cache on one of the clients is flushed.
+	require.NoError(t, rdb2.ScriptFlush(ctx).Err())
+	loaded, err = locker.ScriptsLoaded(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"release": false}, loaded)
+}
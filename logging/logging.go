@@ -4,7 +4,9 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -12,7 +14,7 @@ import (
 
 var (
 	baseConfig = NewConfig()
-	baseLogger = zap.Must(baseConfig.Build())
+	baseLogger = zap.Must(Build(baseConfig))
 )
 
 type contextKey int
@@ -21,10 +23,17 @@ const (
 	contextFieldsKey contextKey = iota
 )
 
+// NewConfig returns a zap.Config configured from the LOG_FORMAT, LOG_LEVEL and
+// LOG_FILE environment variables. Build loggers from it with Build rather than
+// its own Build method: zap's built-in sampler drops errors along with
+// everything else during a burst of logging, and does not honour LOG_FORMAT=tee.
 func NewConfig() zap.Config {
 	var config zap.Config
 
-	development := os.Getenv("LOG_FORMAT") == "development"
+	format := os.Getenv("LOG_FORMAT")
+	// In "tee" mode, the config describes the human-readable console output. The
+	// additional JSON output is attached when the logger is built.
+	development := format == "development" || format == "tee"
 
 	if development {
 		config = newDevelopmentConfig()
@@ -53,6 +62,85 @@ func NewConfig() zap.Config {
 	return config
 }
 
+// WithJSONTee returns a zap.Option which tees all log entries to the passed
+// WriteSyncer as JSON, in addition to the logger's existing output. This is
+// useful for reproducing production log parsing locally while retaining
+// human-readable console output.
+func WithJSONTee(ws zapcore.WriteSyncer, level zapcore.LevelEnabler) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		jsonCore := zapcore.NewCore(zapcore.NewJSONEncoder(newProductionEncoderConfig()), ws, level)
+		return zapcore.NewTee(core, jsonCore)
+	})
+}
+
+// WithSampling returns a zap.Option which samples log entries below
+// ErrorLevel according to the passed SamplingConfig. Entries at ErrorLevel and
+// above are never sampled, so we don't lose errors during a burst of logging,
+// which is exactly when we're most likely to need them.
+func WithSampling(cfg zap.SamplingConfig) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		var opts []zapcore.SamplerOption
+		if cfg.Hook != nil {
+			opts = append(opts, zapcore.SamplerHook(cfg.Hook))
+		}
+		return &samplingBypassCore{
+			Core:    core,
+			sampled: zapcore.NewSamplerWithOptions(core, time.Second, cfg.Initial, cfg.Thereafter, opts...),
+		}
+	})
+}
+
+// samplingBypassCore sends entries at ErrorLevel and above directly to the
+// wrapped core, and all other entries to a sampled version of the same core.
+type samplingBypassCore struct {
+	zapcore.Core
+	sampled zapcore.Core
+}
+
+func (c *samplingBypassCore) With(fields []zapcore.Field) zapcore.Core {
+	return &samplingBypassCore{
+		Core:    c.Core.With(fields),
+		sampled: c.sampled.With(fields),
+	}
+}
+
+func (c *samplingBypassCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level >= zapcore.ErrorLevel {
+		return c.Core.Check(ent, ce)
+	}
+	return c.sampled.Check(ent, ce)
+}
+
+// Build constructs a logger from config in the same way as the package's own
+// loggers, applying opts in addition. If config enables sampling, entries at
+// ErrorLevel and above bypass the sampler (see WithSampling).
+func Build(config zap.Config, opts ...zap.Option) (*zap.Logger, error) {
+	opts = slices.Clone(opts)
+
+	// With LOG_FORMAT=tee, we write console output as normal and JSON output to
+	// LOG_TEE_FILE (or stdout, if that is not set).
+	if os.Getenv("LOG_FORMAT") == "tee" {
+		path := os.Getenv("LOG_TEE_FILE")
+		if path == "" {
+			path = "stdout"
+		}
+		ws, _, err := zap.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithJSONTee(ws, config.Level))
+	}
+
+	// We apply sampling ourselves rather than letting zap do it, so that errors
+	// can bypass the sampler.
+	if config.Sampling != nil {
+		opts = append(opts, WithSampling(*config.Sampling))
+		config.Sampling = nil
+	}
+
+	return config.Build(opts...)
+}
+
 func newDevelopmentConfig() zap.Config {
 	return zap.Config{
 		Level:             zap.NewAtomicLevelAt(zap.DebugLevel),
@@ -1,12 +1,19 @@
 package logging
 
 import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewConfigLevel(t *testing.T) {
@@ -63,6 +70,10 @@ func TestNewConfigFormat(t *testing.T) {
 			value:    "development",
 			encoding: "console",
 		},
+		{
+			value:    "tee",
+			encoding: "console",
+		},
 	}
 
 	for _, tc := range testcases {
@@ -73,3 +84,67 @@ func TestNewConfigFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestWithJSONTee(t *testing.T) {
+	var console, jsonOut bytes.Buffer
+
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	core := zapcore.NewCore(
+		zapcore.NewConsoleEncoder(newDevelopmentEncoderConfig()),
+		zapcore.AddSync(&console),
+		level,
+	)
+	logger := zap.New(core, WithJSONTee(zapcore.AddSync(&jsonOut), level))
+
+	logger.Info("hello world", zap.String("animal", "giraffe"))
+	logger.Debug("not enabled")
+
+	assert.Contains(t, console.String(), "hello world")
+	assert.Contains(t, console.String(), "giraffe")
+	assert.NotContains(t, console.String(), "not enabled")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(jsonOut.Bytes(), &entry))
+	assert.Equal(t, "hello world", entry["message"])
+	assert.Equal(t, "info", entry["severity"])
+	assert.Equal(t, "giraffe", entry["animal"])
+}
+
+func TestWithSamplingNeverDropsErrors(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core, WithSampling(zap.SamplingConfig{
+		Initial:    100,
+		Thereafter: 100,
+	}))
+
+	for i := 0; i < 1000; i++ {
+		logger.Info("info burst")
+		logger.Error("error burst")
+	}
+
+	assert.Equal(t, 1000, logs.FilterMessage("error burst").Len())
+	assert.Less(t, logs.FilterMessage("info burst").Len(), 1000)
+}
+
+func TestBuildNeverDropsErrors(t *testing.T) {
+	t.Setenv("LOG_FORMAT", "")
+	path := filepath.Join(t.TempDir(), "log.json")
+	t.Setenv("LOG_FILE", path)
+
+	config := NewConfig()
+	require.NotNil(t, config.Sampling)
+
+	logger, err := Build(config)
+	require.NoError(t, err)
+
+	for i := 0; i < 1000; i++ {
+		logger.Info("info burst")
+		logger.Error("error burst")
+	}
+	require.NoError(t, logger.Sync())
+
+	out, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, strings.Count(string(out), "error burst"))
+	assert.Less(t, strings.Count(string(out), "info burst"), 1000)
+}
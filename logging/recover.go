@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/zap"
+)
+
+var recoverLogger = New("recover")
+
+// RecoverMiddleware recovers from panics in the wrapped handler. It logs the
+// panic at error level, along with any fields stored on the request context
+// and a stack trace, reports it to Sentry (if configured), and responds with a
+// 500 status code.
+//
+// As with net/http, a panic with http.ErrAbortHandler is not recovered.
+func RecoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			ctx := r.Context()
+
+			log := recoverLogger.With(GetFields(ctx)...)
+			log.Error(
+				"recovered from panic in http handler",
+				zap.String("panic", fmt.Sprint(v)),
+				zap.Stack("stack"),
+			)
+
+			hub := sentry.GetHubFromContext(ctx)
+			if hub == nil {
+				hub = sentry.CurrentHub()
+			}
+			hub.RecoverWithContext(ctx, v)
+
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	original := recoverLogger
+	recoverLogger = zap.New(core)
+	t.Cleanup(func() { recoverLogger = original })
+
+	handler := RecoverMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("kaboom")
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r = r.WithContext(AddFields(r.Context(), zap.String("request_id", "giraffe")))
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zap.ErrorLevel, entries[0].Level)
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "kaboom", fields["panic"])
+	assert.Equal(t, "giraffe", fields["request_id"])
+	assert.Contains(t, fields["stack"], "TestRecoverMiddleware")
+}
+
+func TestRecoverMiddlewarePassesThrough(t *testing.T) {
+	handler := RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
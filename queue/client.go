@@ -2,26 +2,57 @@ package queue
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/replicate/go/shuffleshard"
+	"github.com/replicate/go/telemetry"
+	"github.com/replicate/go/util"
 )
 
+// TraceContextField is the message value in which the writer's trace context
+// is stored when the client is configured with WithTracePropagation.
+const TraceContextField = "__trace_context"
+
+// reclaimBatchSize is the number of messages requested from each call to
+// XAUTOCLAIM by Reclaim.
+const reclaimBatchSize = 100
+
+// broadcastBatchSize is the maximum number of messages returned from each
+// stream by a call to ReadBroadcast.
+const broadcastBatchSize = 100
+
 var (
 	ErrInvalidReadArgs  = fmt.Errorf("queue: invalid read arguments")
 	ErrInvalidWriteArgs = fmt.Errorf("queue: invalid write arguments")
+	ErrQueueMissing     = fmt.Errorf("queue: queue does not exist")
+
+	// ErrNotificationFailed is returned by writes from a client configured
+	// WithRequiredNotifications if the message was written but blocked consumers
+	// could not be notified of it.
+	ErrNotificationFailed = fmt.Errorf("queue: failed to notify consumers of write")
 
 	streamSuffixPattern = regexp.MustCompile(`\A:s(\d+)\z`)
+
+	tracer = telemetry.Tracer("go", "queue")
 )
 
 type Client struct {
-	rdb redis.Cmdable
-	ttl time.Duration // ttl for all keys in queue
+	rdb  redis.Cmdable
+	ttl  time.Duration // ttl for all keys in queue
+	opts clientOptions
 }
 
 type Stats struct {
@@ -31,10 +62,27 @@ type Stats struct {
 	PendingCount int64
 }
 
-func NewClient(rdb redis.Cmdable, ttl time.Duration) *Client {
+// StreamStats holds statistics about a single stream in the queue, as returned
+// by StatsPerStream.
+type StreamStats struct {
+	// StreamID is the key of the stream, e.g. "myqueue:s3"
+	StreamID string
+	// Len is the length of the stream, as reported by XLEN
+	Len int64
+	// PendingCount is the count of pending entries in the stream, as reported
+	// by XPENDING
+	PendingCount int64
+}
+
+func NewClient(rdb redis.Cmdable, ttl time.Duration, options ...Option) *Client {
+	var opts clientOptions
+	for _, o := range options {
+		o.apply(&opts)
+	}
 	return &Client{
-		rdb: rdb,
-		ttl: ttl,
+		rdb:  rdb,
+		ttl:  ttl,
+		opts: opts,
 	}
 }
 
@@ -44,6 +92,13 @@ func (c *Client) Prepare(ctx context.Context) error {
 	return prepare(ctx, c.rdb)
 }
 
+// ScriptsLoaded reports whether each of the queue's scripts is present in the
+// Redis script cache, keyed by script name. This can be used in health checks
+// to detect a flushed script cache (e.g. after a Redis restart).
+func (c *Client) ScriptsLoaded(ctx context.Context) (map[string]bool, error) {
+	return scriptsLoaded(ctx, c.rdb)
+}
+
 // Len calculates the aggregate length (XLEN) of the queue. It adds up the
 // lengths of all the streams in the queue.
 func (c *Client) Len(ctx context.Context, name string) (int64, error) {
@@ -59,11 +114,177 @@ func (c *Client) Stats(ctx context.Context, queue string, group string) (Stats,
 	return Stats{Len: out[0], PendingCount: out[1]}, nil
 }
 
+// StatsPerStream calculates statistics about each of the streams in the queue
+// for the consumer group, in stream order. Unlike Stats, this shows how entries
+// are distributed across the queue, which is useful for detecting imbalance
+// caused by skewed shard keys.
+func (c *Client) StatsPerStream(ctx context.Context, queue string, group string) ([]StreamStats, error) {
+	out, err := statsPerStreamScript.RunRO(ctx, c.rdb, []string{queue}, group).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(out)%2 != 0 {
+		return nil, fmt.Errorf("queue: unexpected stats reply length %d", len(out))
+	}
+	stats := make([]StreamStats, len(out)/2)
+	for i := range stats {
+		stats[i] = StreamStats{
+			StreamID:     fmt.Sprintf("%s:s%d", queue, i),
+			Len:          out[2*i],
+			PendingCount: out[2*i+1],
+		}
+	}
+	return stats, nil
+}
+
+// OldestPendingAge returns the age of the oldest message which has been
+// delivered to a consumer in the group but not yet acknowledged, across all
+// the streams in the queue. The age is determined from the timestamp embedded
+// in the message's stream ID. If there are no pending messages, it returns 0.
+func (c *Client) OldestPendingAge(ctx context.Context, queue string, group string) (time.Duration, error) {
+	id, err := oldestPendingScript.RunRO(ctx, c.rdb, []string{queue}, group).Text()
+	if err == redis.Nil {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	msStr, _, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid stream ID: %q", id)
+	}
+	ms, err := strconv.ParseInt(msStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stream ID: %q: %w", id, err)
+	}
+	return time.Since(time.UnixMilli(ms)), nil
+}
+
+// PruneConsumers removes consumers from the consumer group which have been idle
+// for longer than idleThreshold and have no pending messages, across all the
+// streams in the queue (including the notifications stream). Such consumers are
+// typically left behind by workers which have exited. It returns the number of
+// consumers removed from each stream, summed across streams.
+func (c *Client) PruneConsumers(ctx context.Context, name string, group string, idleThreshold time.Duration) (int, error) {
+	if name == "" {
+		return 0, fmt.Errorf("%w: name cannot be empty", ErrInvalidReadArgs)
+	}
+	if group == "" {
+		return 0, fmt.Errorf("%w: group cannot be empty", ErrInvalidReadArgs)
+	}
+
+	keys, err := c.streamKeys(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	keys = append(keys, name+":notifications")
+
+	pruned := 0
+	for _, stream := range keys {
+		consumers, err := c.rdb.XInfoConsumers(ctx, stream, group).Result()
+		if err != nil && (strings.HasPrefix(err.Error(), "NOGROUP") || strings.HasPrefix(err.Error(), "ERR no such key")) {
+			// The stream or group doesn't exist, so there are no consumers.
+			continue
+		} else if err != nil {
+			return pruned, err
+		}
+
+		for _, consumer := range consumers {
+			if consumer.Pending > 0 || consumer.Idle <= idleThreshold {
+				continue
+			}
+			if err := c.rdb.XGroupDelConsumer(ctx, stream, group, consumer.Name).Err(); err != nil {
+				return pruned, err
+			}
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
+
+// Ack acknowledges messages which were read from the queue by a consumer in the
+// group, removing them from the group's pending entries. Message IDs are only
+// unique within a stream, so each message is acknowledged only on the stream
+// from which it was read.
+func (c *Client) Ack(ctx context.Context, group string, msgs ...*Message) error {
+	if group == "" {
+		return fmt.Errorf("%w: group cannot be empty", ErrInvalidReadArgs)
+	}
+
+	var streams []string
+	ids := make(map[string][]string)
+	for _, msg := range msgs {
+		if msg == nil {
+			return fmt.Errorf("%w: message cannot be nil", ErrInvalidReadArgs)
+		}
+		if msg.Stream == "" {
+			return fmt.Errorf("%w: message %s has no stream", ErrInvalidReadArgs, msg.ID)
+		}
+		if _, ok := ids[msg.Stream]; !ok {
+			streams = append(streams, msg.Stream)
+		}
+		ids[msg.Stream] = append(ids[msg.Stream], msg.ID)
+	}
+
+	switch len(streams) {
+	case 0:
+		return nil
+	case 1:
+		return c.rdb.XAck(ctx, streams[0], group, ids[streams[0]]...).Err()
+	}
+
+	pipe := c.rdb.Pipeline()
+	for _, stream := range streams {
+		pipe.XAck(ctx, stream, group, ids[stream]...)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// AckMessage acknowledges a single message which was read from the queue by a
+// consumer in the group. See Ack.
+func (c *Client) AckMessage(ctx context.Context, group string, msg *Message) error {
+	if msg == nil {
+		return fmt.Errorf("%w: message cannot be nil", ErrInvalidReadArgs)
+	}
+	return c.Ack(ctx, group, msg)
+}
+
+// streamKeys returns the keys of the streams which currently make up the named
+// queue.
+func (c *Client) streamKeys(ctx context.Context, name string) ([]string, error) {
+	streams, err := c.rdb.HGet(ctx, name+":meta", "streams").Int()
+	if err == redis.Nil {
+		streams = 1
+	} else if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, streams+1)
+	for i := range streams {
+		keys = append(keys, fmt.Sprintf("%s:s%d", name, i))
+	}
+	return keys, nil
+}
+
 // Read a single message from the queue. If the Block field of args is
 // non-zero, the call may block for up to that duration waiting for a new
 // message.
 //
+// If the RecoverPending field of args is set, any messages which were
+// previously delivered to the consumer but not yet acknowledged are returned
+// before any new messages.
+//
 // If no message is available err will be [Empty].
+//
+// If the message was written with trace propagation enabled (see
+// WithTracePropagation), the span for the read is linked to the writer's span,
+// and the trace context is removed from the message's values.
+//
+// Values compressed by a client configured WithCompression are decompressed.
+// If decompression fails, the message is returned along with the error, so
+// that the caller can still acknowledge it.
 func (c *Client) Read(ctx context.Context, args *ReadArgs) (*Message, error) {
 	if args == nil {
 		return nil, fmt.Errorf("%w: args cannot be nil", ErrInvalidReadArgs)
@@ -78,12 +299,343 @@ func (c *Client) Read(ctx context.Context, args *ReadArgs) (*Message, error) {
 		return nil, fmt.Errorf("%w: consumer cannot be empty", ErrInvalidReadArgs)
 	}
 
+	ctx, span := tracer.Start(
+		ctx,
+		"queue.read",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("queue.name", args.Name),
+			attribute.String("queue.group", args.Group),
+		),
+	)
+	defer span.End()
+
+	msg, err := c.readMessage(ctx, args)
+	if err != nil {
+		if err != Empty {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return msg, err
+	}
+
+	span.SetAttributes(
+		attribute.String("queue.stream", msg.Stream),
+		attribute.String("queue.message_id", msg.ID),
+	)
+	if err := decompress(msg); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return msg, err
+	}
+	if link, ok := extractTraceContext(msg); ok {
+		span.AddLink(link)
+	}
+	return msg, nil
+}
+
+func (c *Client) readMessage(ctx context.Context, args *ReadArgs) (*Message, error) {
+	if args.RecoverPending {
+		msg, err := c.readPending(ctx, args)
+		if msg != nil || err != nil {
+			return msg, err
+		}
+	}
+
 	if args.PreferStream != "" {
 		return c.readWithPreferredStream(ctx, args)
 	}
 	return c.read(ctx, args)
 }
 
+// readPending returns the first message in the consumer's pending entries list
+// (i.e. delivered but not yet acknowledged) from any of the queue's streams,
+// or nil if there are none.
+func (c *Client) readPending(ctx context.Context, args *ReadArgs) (*Message, error) {
+	streams, err := c.streamKeys(ctx, args.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stream := range streams {
+		start := "0"
+		for {
+			result, err := c.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    args.Group,
+				Consumer: args.Consumer,
+				Streams:  []string{stream, start},
+				Block:    -1,
+				Count:    1,
+			}).Result()
+			if err != nil && strings.HasPrefix(err.Error(), "NOGROUP") {
+				// The stream or group doesn't exist, so nothing can be pending.
+				break
+			} else if err != nil && err != redis.Nil {
+				return nil, err
+			}
+			if len(result) == 0 || len(result[0].Messages) == 0 {
+				break
+			}
+
+			// Messages which have been deleted from the stream remain in the pending
+			// entries list with no values. Skip past them.
+			for _, m := range result[0].Messages {
+				if m.Values != nil {
+					return &Message{
+						Stream: result[0].Stream,
+						ID:     m.ID,
+						Values: m.Values,
+					}, nil
+				}
+				start = m.ID
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// Drain reads all the messages currently available to the consumer, without
+// blocking, and passes each to handler, acknowledging it if handler returns
+// nil. It returns once the queue is empty, and is intended for finishing
+// outstanding work when a consumer is shutting down.
+//
+// Messages for which handler returns an error are left pending, so that they
+// can be recovered (see Reclaim), and processing continues with the next
+// message. The errors are joined and returned once the queue is empty, or when
+// ctx is cancelled, which is checked before each message is read.
+func (c *Client) Drain(ctx context.Context, name string, group string, consumer string, handler func(*Message) error) error {
+	if handler == nil {
+		return fmt.Errorf("%w: handler cannot be nil", ErrInvalidReadArgs)
+	}
+
+	args := &ReadArgs{
+		Name:     name,
+		Group:    group,
+		Consumer: consumer,
+	}
+
+	var errs []error
+	for {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		msg, err := c.Read(ctx, args)
+		if err == Empty {
+			return errors.Join(errs...)
+		} else if err != nil && msg == nil {
+			return errors.Join(append(errs, err)...)
+		} else if err != nil {
+			// The message couldn't be decompressed. Leave it pending.
+			errs = append(errs, fmt.Errorf("message %s: %w", msg.ID, err))
+			continue
+		}
+
+		if err := handler(msg); err != nil {
+			errs = append(errs, fmt.Errorf("message %s: %w", msg.ID, err))
+			continue
+		}
+		if err := c.AckMessage(ctx, group, msg); err != nil {
+			errs = append(errs, fmt.Errorf("message %s: error acknowledging: %w", msg.ID, err))
+		}
+	}
+}
+
+// Peek returns up to n messages from the head of each of the queue's streams,
+// without reading them through a consumer group. Peeked messages are not
+// delivered to any consumer and no group state is changed, so this is suitable
+// for sampling the queue for monitoring purposes.
+//
+// As with ReadBroadcast, any trace context is removed from the messages'
+// values and compressed values are decompressed.
+func (c *Client) Peek(ctx context.Context, name string, n int) ([]*Message, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidReadArgs)
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: n must be > 0", ErrInvalidReadArgs)
+	}
+
+	keys, err := c.streamKeys(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := c.rdb.Pipeline()
+	cmds := make([]*redis.XMessageSliceCmd, len(keys))
+	for i, stream := range keys {
+		cmds[i] = pipe.XRangeN(ctx, stream, "-", "+", int64(n))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	var msgs []*Message
+	for i, cmd := range cmds {
+		for _, m := range cmd.Val() {
+			msg := &Message{
+				Stream: keys[i],
+				ID:     m.ID,
+				Values: m.Values,
+			}
+			_, _ = extractTraceContext(msg)
+			if err := decompress(msg); err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
+
+// Reclaim transfers messages which were delivered to other consumers in the
+// group, but which have not been acknowledged for at least args.MinIdle, to
+// args.Consumer, across all the streams in the queue. This allows messages held
+// by consumers which have died to be reprocessed. The reclaimed messages are
+// returned, and remain pending until they are acknowledged.
+//
+// As with Read, any trace context is removed from the messages' values and
+// compressed values are decompressed. If decompression fails, the message is
+// returned as-is along with the error.
+func (c *Client) Reclaim(ctx context.Context, args *ReclaimArgs) ([]*Message, error) {
+	if args == nil {
+		return nil, fmt.Errorf("%w: args cannot be nil", ErrInvalidReadArgs)
+	}
+	if args.Name == "" {
+		return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidReadArgs)
+	}
+	if args.Group == "" {
+		return nil, fmt.Errorf("%w: group cannot be empty", ErrInvalidReadArgs)
+	}
+	if args.Consumer == "" {
+		return nil, fmt.Errorf("%w: consumer cannot be empty", ErrInvalidReadArgs)
+	}
+	if args.Count < 0 {
+		return nil, fmt.Errorf("%w: count must be >= 0", ErrInvalidReadArgs)
+	}
+
+	keys, err := c.streamKeys(ctx, args.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []*Message
+	var errs []error
+	for _, stream := range keys {
+		start := "0-0"
+		for args.Count == 0 || len(msgs) < args.Count {
+			count := int64(reclaimBatchSize)
+			if args.Count > 0 {
+				count = int64(min(args.Count-len(msgs), reclaimBatchSize))
+			}
+			claimed, next, err := c.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   stream,
+				Group:    args.Group,
+				Consumer: args.Consumer,
+				MinIdle:  args.MinIdle,
+				Start:    start,
+				Count:    count,
+			}).Result()
+			if err != nil && (strings.HasPrefix(err.Error(), "NOGROUP") || strings.HasPrefix(err.Error(), "ERR no such key")) {
+				// The stream or group doesn't exist, so nothing can be pending.
+				break
+			} else if err != nil {
+				return msgs, err
+			}
+
+			for _, m := range claimed {
+				// Messages which have been deleted from the stream have no values.
+				if m.Values == nil {
+					continue
+				}
+				msg := &Message{Stream: stream, ID: m.ID, Values: m.Values}
+				_, _ = extractTraceContext(msg)
+				if err := decompress(msg); err != nil {
+					errs = append(errs, err)
+				}
+				msgs = append(msgs, msg)
+			}
+
+			if next == "0-0" {
+				break
+			}
+			start = next
+		}
+	}
+	return msgs, errors.Join(errs...)
+}
+
+// ReadBroadcast reads all messages from the queue's streams which follow the
+// positions given in lastIDs, without using a consumer group. Unlike Read,
+// every broadcast reader sees every message, and messages are not tracked as
+// pending or acknowledged.
+//
+// lastIDs maps stream names to the ID of the last message read from each
+// stream. Streams missing from lastIDs are read from the beginning. The
+// returned map contains updated positions for all the queue's streams, and
+// should be passed to the next call to ReadBroadcast.
+//
+// At most 100 messages are returned from each stream, so a reader starting
+// from the beginning of a long queue should call ReadBroadcast repeatedly
+// until it returns no messages.
+func (c *Client) ReadBroadcast(ctx context.Context, name string, lastIDs map[string]string) ([]*Message, map[string]string, error) {
+	if name == "" {
+		return nil, nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidReadArgs)
+	}
+
+	streams, err := c.streamKeys(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cursors := make(map[string]string, len(lastIDs))
+	for k, v := range lastIDs {
+		cursors[k] = v
+	}
+
+	// XREAD takes all the stream names followed by all the IDs.
+	args := make([]string, 2*len(streams))
+	for i, stream := range streams {
+		id, ok := cursors[stream]
+		if !ok {
+			id = "0"
+			cursors[stream] = id
+		}
+		args[i] = stream
+		args[len(streams)+i] = id
+	}
+
+	result, err := c.rdb.XRead(ctx, &redis.XReadArgs{
+		Streams: args,
+		Count:   broadcastBatchSize,
+		Block:   -1, // don't block
+	}).Result()
+	if err == redis.Nil {
+		return []*Message{}, cursors, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	var msgs []*Message
+	for _, stream := range result {
+		for _, m := range stream.Messages {
+			msg := &Message{
+				Stream: stream.Stream,
+				ID:     m.ID,
+				Values: m.Values,
+			}
+			// There's no span to link to, but the trace context shouldn't leak
+			// into the values.
+			_, _ = extractTraceContext(msg)
+			if err := decompress(msg); err != nil {
+				return nil, nil, err
+			}
+			msgs = append(msgs, msg)
+			cursors[stream.Stream] = m.ID
+		}
+	}
+	return msgs, cursors, nil
+}
+
 func (c *Client) readWithPreferredStream(ctx context.Context, args *ReadArgs) (*Message, error) {
 	// First we validate PreferStream. If it makes sense, we'll do an XREADGROUP
 	// against that stream. If it doesn't, we'll start things off with a normal
@@ -156,7 +708,7 @@ func (c *Client) read(ctx context.Context, args *ReadArgs) (*Message, error) {
 func (c *Client) readOnce(ctx context.Context, args *ReadArgs) (*Message, error) {
 	cmdKeys := []string{args.Name}
 	cmdArgs := []any{int(c.ttl.Seconds()), args.Group, args.Consumer}
-	result, err := readScript.Run(ctx, c.rdb, cmdKeys, cmdArgs...).Result()
+	result, err := c.runScript(ctx, readScript, cmdKeys, cmdArgs...).Result()
 	switch {
 	case err == redis.Nil:
 		return nil, Empty
@@ -211,55 +763,235 @@ func (c *Client) waitOnce(ctx context.Context, args *ReadArgs) (bool, error) {
 // Write a message to the queue. The message will be written to the shortest
 // queue in the tenant's shard, which is determined by the ShardKey in args.
 func (c *Client) Write(ctx context.Context, args *WriteArgs) (string, error) {
-	if args == nil {
-		return "", fmt.Errorf("%w: args cannot be nil", ErrInvalidWriteArgs)
+	if err := validateWriteArgs(args); err != nil {
+		return "", err
 	}
-	if args.Name == "" {
-		return "", fmt.Errorf("%w: name cannot be empty", ErrInvalidWriteArgs)
+	return c.write(ctx, args)
+}
+
+// WriteBatch writes multiple messages, as Write, but pipelines the writes so
+// that they are sent in a single round trip. The IDs of the written messages
+// are returned in the same order as args.
+//
+// All args are validated before anything is written. If any write fails, the
+// first error is returned, along with the IDs of those messages that were
+// written successfully (the IDs of the failed writes are left empty).
+func (c *Client) WriteBatch(ctx context.Context, args []*WriteArgs) ([]string, error) {
+	for i, a := range args {
+		if err := validateWriteArgs(a); err != nil {
+			return nil, fmt.Errorf("args[%d]: %w", i, err)
+		}
+	}
+
+	ids := make([]string, len(args))
+	if len(args) == 0 {
+		return ids, nil
+	}
+
+	indices := make([]int, len(args))
+	for i := range indices {
+		indices[i] = i
 	}
-	if args.Streams == 0 {
-		args.Streams = 1
+
+	noscript, err := c.writePipelined(ctx, args, indices, ids)
+	if err != nil || len(noscript) == 0 {
+		return ids, err
+	}
+
+	// See runScript: if the script cache has been flushed, reload the scripts
+	// and retry (only) the writes which failed.
+	if err := c.Prepare(ctx); err != nil {
+		return ids, fmt.Errorf("error reloading scripts after NOSCRIPT: %w", err)
+	}
+	noscript, err = c.writePipelined(ctx, args, noscript, ids)
+	if err != nil {
+		return ids, err
+	}
+	if len(noscript) > 0 {
+		return ids, fmt.Errorf("args[%d]: write script not loaded after reload", noscript[0])
+	}
+	return ids, nil
+}
+
+func validateWriteArgs(args *WriteArgs) error {
+	if args == nil {
+		return fmt.Errorf("%w: args cannot be nil", ErrInvalidWriteArgs)
 	}
-	if args.StreamsPerShard == 0 {
-		args.StreamsPerShard = 1
+	if args.Name == "" {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidWriteArgs)
 	}
+	args.Streams = util.Default(args.Streams, 1)
+	args.StreamsPerShard = util.Default(args.StreamsPerShard, 1)
 	if args.Streams < 0 {
-		return "", fmt.Errorf("%w: streams must be > 0", ErrInvalidWriteArgs)
+		return fmt.Errorf("%w: streams must be > 0", ErrInvalidWriteArgs)
 	}
 	if args.StreamsPerShard < 0 {
-		return "", fmt.Errorf("%w: streams per shard must be > 0", ErrInvalidWriteArgs)
+		return fmt.Errorf("%w: streams per shard must be > 0", ErrInvalidWriteArgs)
 	}
 	if args.StreamsPerShard > args.Streams {
-		return "", fmt.Errorf("%w: streams per shard must be <= streams", ErrInvalidWriteArgs)
+		return fmt.Errorf("%w: streams per shard must be <= streams", ErrInvalidWriteArgs)
 	}
 	if len(args.ShardKey) == 0 {
-		return "", fmt.Errorf("%w: shard key cannot be empty", ErrInvalidWriteArgs)
+		return fmt.Errorf("%w: shard key cannot be empty", ErrInvalidWriteArgs)
+	}
+	if args.MaxLen < 0 {
+		return fmt.Errorf("%w: max len must be >= 0", ErrInvalidWriteArgs)
 	}
 	if len(args.Values) == 0 {
-		return "", fmt.Errorf("%w: values cannot be empty", ErrInvalidWriteArgs)
+		return fmt.Errorf("%w: values cannot be empty", ErrInvalidWriteArgs)
 	}
-
-	return c.write(ctx, args)
+	return nil
 }
 
 func (c *Client) write(ctx context.Context, args *WriteArgs) (string, error) {
+	cmdKeys, cmdArgs := c.writeCmd(ctx, args)
+	id, err := c.runScript(ctx, writeScript, cmdKeys, cmdArgs...).Text()
+	return id, writeErr(args, err)
+}
+
+// writePipelined executes the writes for the given indices of args in a single
+// pipeline, storing the resulting IDs in ids. It returns the indices of any
+// writes which failed with NOSCRIPT, and the first other error encountered.
+func (c *Client) writePipelined(ctx context.Context, args []*WriteArgs, indices []int, ids []string) ([]int, error) {
+	pipe := c.rdb.Pipeline()
+	cmds := make([]*redis.Cmd, len(indices))
+	for j, i := range indices {
+		cmdKeys, cmdArgs := c.writeCmd(ctx, args[i])
+		cmds[j] = writeScript.EvalSha(ctx, pipe, cmdKeys, cmdArgs...)
+	}
+	// Errors are inspected per command below.
+	_, _ = pipe.Exec(ctx)
+
+	var noscript []int
+	var firstErr error
+	for j, i := range indices {
+		id, err := cmds[j].Text()
+		switch {
+		case err == nil:
+			ids[i] = id
+		case strings.HasPrefix(err.Error(), "NOSCRIPT"):
+			noscript = append(noscript, i)
+		case firstErr == nil:
+			firstErr = fmt.Errorf("args[%d]: %w", i, writeErr(args[i], err))
+		}
+	}
+	return noscript, firstErr
+}
+
+func (c *Client) writeCmd(ctx context.Context, args *WriteArgs) ([]string, []any) {
 	shard := shuffleshard.Get(args.Streams, args.StreamsPerShard, args.ShardKey)
 
 	cmdKeys := []string{args.Name}
-	// Capacity: 3 (for seconds, streams, n) + len(shard) + 2*len(values)
-	cmdArgs := make([]any, 0, 3+len(shard)+2*len(args.Values))
+	// Capacity: 8 (for seconds, streams, mustexist, notify, maxlen, approx, group, n) + len(shard) + 2*len(values) + 4 (for trace context and compression marker)
+	cmdArgs := make([]any, 0, 8+len(shard)+2*len(args.Values)+4)
+
+	mustExist := 0
+	if args.MustExist {
+		mustExist = 1
+	}
+	notify := 0
+	if c.opts.RequireNotifications {
+		notify = 1
+	}
+	approx := 0
+	if args.Approx {
+		approx = 1
+	}
 
 	cmdArgs = append(cmdArgs, int(c.ttl.Seconds()))
 	cmdArgs = append(cmdArgs, args.Streams)
+	cmdArgs = append(cmdArgs, mustExist)
+	cmdArgs = append(cmdArgs, notify)
+	cmdArgs = append(cmdArgs, args.MaxLen)
+	cmdArgs = append(cmdArgs, approx)
+	cmdArgs = append(cmdArgs, args.BalanceGroup)
 	cmdArgs = append(cmdArgs, len(shard))
 	for _, s := range shard {
 		cmdArgs = append(cmdArgs, s)
 	}
 	for k, v := range args.Values {
+		if k == c.opts.CompressedField {
+			if compressed, ok := compress(v); ok {
+				cmdArgs = append(cmdArgs, k, compressed, CompressedField, k)
+				continue
+			}
+		}
 		cmdArgs = append(cmdArgs, k, v)
 	}
+	if c.opts.PropagateTraceContext {
+		if tc := injectTraceContext(ctx); tc != "" {
+			cmdArgs = append(cmdArgs, TraceContextField, tc)
+		}
+	}
+
+	return cmdKeys, cmdArgs
+}
+
+// injectTraceContext serializes the trace context in ctx for storage in a
+// message, returning an empty string if there is no trace context to
+// propagate.
+func injectTraceContext(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(carrier)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// extractTraceContext removes any trace context stored by the writer from the
+// message's values and returns a link to the writer's span.
+func extractTraceContext(msg *Message) (trace.Link, bool) {
+	v, ok := msg.Values[TraceContextField]
+	if !ok {
+		return trace.Link{}, false
+	}
+	delete(msg.Values, TraceContextField)
+
+	s, ok := v.(string)
+	if !ok {
+		return trace.Link{}, false
+	}
+	carrier := propagation.MapCarrier{}
+	if err := json.Unmarshal([]byte(s), &carrier); err != nil {
+		return trace.Link{}, false
+	}
+	link := trace.LinkFromContext(otel.GetTextMapPropagator().Extract(context.Background(), carrier))
+	if !link.SpanContext.IsValid() {
+		return trace.Link{}, false
+	}
+	return link, true
+}
 
-	return writeScript.Run(ctx, c.rdb, cmdKeys, cmdArgs...).Text()
+func writeErr(args *WriteArgs, err error) error {
+	if err != nil && strings.HasPrefix(err.Error(), "QUEUEMISSING") {
+		return fmt.Errorf("%w: %s", ErrQueueMissing, args.Name)
+	}
+	if err != nil && strings.HasPrefix(err.Error(), "NOTIFYFAILED") {
+		return fmt.Errorf("%w: %s: %s", ErrNotificationFailed, args.Name, strings.TrimPrefix(err.Error(), "NOTIFYFAILED "))
+	}
+	return err
+}
+
+// runScript runs the given script. Script.Run already falls back from EVALSHA
+// to EVAL if the script cache has been flushed (e.g. after a Redis restart),
+// but in some proxy setups the fallback itself fails with NOSCRIPT. In that
+// case we reload all the scripts and retry once.
+func (c *Client) runScript(ctx context.Context, script *redis.Script, keys []string, args ...any) *redis.Cmd {
+	cmd := script.Run(ctx, c.rdb, keys, args...)
+	if err := cmd.Err(); err == nil || !strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		return cmd
+	}
+
+	if err := c.Prepare(ctx); err != nil {
+		cmd.SetErr(fmt.Errorf("error reloading scripts after NOSCRIPT: %w", err))
+		return cmd
+	}
+	return script.EvalSha(ctx, c.rdb, keys, args...)
 }
 
 func parse(v any) (*Message, error) {
@@ -1,20 +1,26 @@
 package queue_test
 
 import (
+	"context"
 	crand "crypto/rand"
 	"errors"
 	"fmt"
 	"math/rand"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-redis/redismock/v9"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	exprand "golang.org/x/exp/rand"
 
 	"github.com/replicate/go/queue"
@@ -249,6 +255,264 @@ func TestClientReadIntegration(t *testing.T) {
 	}
 }
 
+func TestClientReadRecoverPendingIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl)
+	require.NoError(t, client.Prepare(ctx))
+
+	for i := range 5 {
+		_, err := client.Write(ctx, &queue.WriteArgs{
+			Name:            "myqueue",
+			Streams:         2,
+			StreamsPerShard: 2,
+			ShardKey:        []byte("panda"),
+			Values: map[string]any{
+				"idx": strconv.Itoa(i),
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	readArgs := &queue.ReadArgs{
+		Name:     "myqueue",
+		Group:    "mygroup",
+		Consumer: "mygroup:123",
+	}
+
+	// Read three messages without acknowledging them.
+	delivered := make([]string, 0, 3)
+	for range 3 {
+		msg, err := client.Read(ctx, readArgs)
+		require.NoError(t, err)
+		delivered = append(delivered, msg.Values["idx"].(string))
+	}
+
+	// Simulate a restart: pending messages are redelivered before new ones.
+	readArgs.RecoverPending = true
+
+	recovered := make([]string, 0, 3)
+	for range 3 {
+		msg, err := client.Read(ctx, readArgs)
+		require.NoError(t, err)
+		recovered = append(recovered, msg.Values["idx"].(string))
+		require.NoError(t, rdb.XAck(ctx, msg.Stream, "mygroup", msg.ID).Err())
+	}
+	assert.ElementsMatch(t, delivered, recovered)
+
+	remaining := make([]string, 0, 2)
+	for {
+		msg, err := client.Read(ctx, readArgs)
+		if errors.Is(err, queue.Empty) {
+			break
+		}
+		require.NoError(t, err)
+		remaining = append(remaining, msg.Values["idx"].(string))
+		require.NoError(t, rdb.XAck(ctx, msg.Stream, "mygroup", msg.ID).Err())
+	}
+	assert.Len(t, remaining, 2)
+	assert.ElementsMatch(t, []string{"0", "1", "2", "3", "4"}, append(delivered, remaining...))
+}
+
+func TestClientOldestPendingAgeIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl)
+	require.NoError(t, client.Prepare(ctx))
+
+	// No queue, no pending messages
+	age, err := client.OldestPendingAge(ctx, "myqueue", "mygroup")
+	require.NoError(t, err)
+	assert.Zero(t, age)
+
+	for i := range 4 {
+		_, err := client.Write(ctx, &queue.WriteArgs{
+			Name:            "myqueue",
+			Streams:         2,
+			StreamsPerShard: 2,
+			ShardKey:        []byte("panda"),
+			Values: map[string]any{
+				"idx": strconv.Itoa(i),
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	// Read two messages without acknowledging them.
+	for range 2 {
+		_, err := client.Read(ctx, &queue.ReadArgs{
+			Name:     "myqueue",
+			Group:    "mygroup",
+			Consumer: "mygroup:123",
+		})
+		require.NoError(t, err)
+	}
+
+	age1, err := client.OldestPendingAge(ctx, "myqueue", "mygroup")
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+
+	age2, err := client.OldestPendingAge(ctx, "myqueue", "mygroup")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, age2-age1, 50*time.Millisecond)
+}
+
+func TestClientPruneConsumersIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl)
+	require.NoError(t, client.Prepare(ctx))
+
+	// No queue, nothing to prune
+	n, err := client.PruneConsumers(ctx, "myqueue", "mygroup", time.Minute)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	_, err = client.Write(ctx, &queue.WriteArgs{
+		Name:     "myqueue",
+		ShardKey: []byte("panda"),
+		Values:   map[string]any{"name": "panda"},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, rdb.XGroupCreate(ctx, "myqueue:s0", "mygroup", "0").Err())
+	require.NoError(t, rdb.XGroupCreateConsumer(ctx, "myqueue:s0", "mygroup", "idle1").Err())
+	require.NoError(t, rdb.XGroupCreateConsumer(ctx, "myqueue:s0", "mygroup", "idle2").Err())
+	// A consumer which has a pending message is never pruned.
+	require.NoError(t, rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "mygroup",
+		Consumer: "busy",
+		Streams:  []string{"myqueue:s0", ">"},
+		Count:    1,
+		Block:    -1,
+	}).Err())
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, rdb.XGroupCreateConsumer(ctx, "myqueue:s0", "mygroup", "active").Err())
+
+	n, err = client.PruneConsumers(ctx, "myqueue", "mygroup", 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	consumers, err := rdb.XInfoConsumers(ctx, "myqueue:s0", "mygroup").Result()
+	require.NoError(t, err)
+	names := make([]string, len(consumers))
+	for i, c := range consumers {
+		names[i] = c.Name
+	}
+	assert.ElementsMatch(t, []string{"active", "busy"}, names)
+}
+
+func TestClientReadBroadcastIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl)
+	require.NoError(t, client.Prepare(ctx))
+
+	write := func(i int) {
+		_, err := client.Write(ctx, &queue.WriteArgs{
+			Name:            "myqueue",
+			Streams:         4,
+			StreamsPerShard: 2,
+			ShardKey:        []byte(strconv.Itoa(i)),
+			Values: map[string]any{
+				"idx": strconv.Itoa(i),
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	type reader struct {
+		cursors map[string]string
+		seen    []string
+	}
+	readers := []*reader{{}, {}}
+
+	readAll := func(r *reader) {
+		msgs, cursors, err := client.ReadBroadcast(ctx, "myqueue", r.cursors)
+		require.NoError(t, err)
+		for _, msg := range msgs {
+			r.seen = append(r.seen, msg.Values["idx"].(string))
+		}
+		r.cursors = cursors
+	}
+
+	for i := range 10 {
+		write(i)
+	}
+	for _, r := range readers {
+		readAll(r)
+	}
+	// Nothing new to read
+	for _, r := range readers {
+		readAll(r)
+	}
+	for i := 10; i < 20; i++ {
+		write(i)
+	}
+	for _, r := range readers {
+		readAll(r)
+	}
+
+	expected := make([]string, 20)
+	for i := range expected {
+		expected[i] = strconv.Itoa(i)
+	}
+	for _, r := range readers {
+		assert.ElementsMatch(t, expected, r.seen)
+	}
+}
+
+func TestClientReadBroadcastBatchesIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl)
+	require.NoError(t, client.Prepare(ctx))
+
+	for i := range 250 {
+		_, err := client.Write(ctx, &queue.WriteArgs{
+			Name:            "myqueue",
+			Streams:         1,
+			StreamsPerShard: 1,
+			ShardKey:        []byte("tenant"),
+			Values: map[string]any{
+				"idx": strconv.Itoa(i),
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	// A new reader is given the backlog in batches, and continues from the
+	// returned cursors.
+	var cursors map[string]string
+	var batches []int
+	seen := 0
+	for {
+		msgs, next, err := client.ReadBroadcast(ctx, "myqueue", cursors)
+		require.NoError(t, err)
+		if len(msgs) == 0 {
+			break
+		}
+		batches = append(batches, len(msgs))
+		seen += len(msgs)
+		cursors = next
+	}
+
+	assert.Equal(t, []int{100, 100, 50}, batches)
+	assert.Equal(t, 250, seen)
+}
+
 func TestClientWriteIntegration(t *testing.T) {
 	ctx := test.Context(t)
 	rdb := test.Redis(ctx, t)
@@ -257,92 +521,865 @@ func TestClientWriteIntegration(t *testing.T) {
 	client := queue.NewClient(rdb, ttl)
 	require.NoError(t, client.Prepare(ctx))
 
-	for i := range 10 {
+	for i := range 10 {
+		_, err := client.Write(ctx, &queue.WriteArgs{
+			Name:            "myqueue",
+			Streams:         2,
+			StreamsPerShard: 1,
+			ShardKey:        []byte("panda"),
+			Values: map[string]any{
+				"name": "panda",
+				"idx":  i,
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	for i := range 5 {
+		_, err := client.Write(ctx, &queue.WriteArgs{
+			Name:            "myqueue",
+			Streams:         2,
+			StreamsPerShard: 1,
+			ShardKey:        []byte("giraffe"),
+			Values: map[string]any{
+				"name": "giraffe",
+				"idx":  i,
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	// meta key contains the number of streams
+	val, err := rdb.HGet(ctx, "myqueue:meta", "streams").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "2", val)
+	// notification stream contains at least one message
+	{
+		ln, err := rdb.XLen(ctx, "myqueue:notifications").Result()
+		require.NoError(t, err)
+		assert.Greater(t, ln, int64(0))
+	}
+
+	// "giraffe" is assigned to shard 0
+	{
+		l0, err := rdb.XLen(ctx, "myqueue:s0").Result()
+		require.NoError(t, err)
+		assert.EqualValues(t, 5, l0)
+
+		values, err := rdb.XRange(ctx, "myqueue:s0", "-", "+").Result()
+		require.NoError(t, err)
+		assert.Len(t, values, 5)
+		for i, v := range values {
+			assert.Equal(t, map[string]interface{}{
+				"name": "giraffe",
+				"idx":  strconv.Itoa(i),
+			}, v.Values)
+		}
+	}
+
+	// "panda" is assigned to shard 1
+	{
+		l1, err := rdb.XLen(ctx, "myqueue:s1").Result()
+		require.NoError(t, err)
+		assert.EqualValues(t, 10, l1)
+
+		values, err := rdb.XRange(ctx, "myqueue:s1", "-", "+").Result()
+		require.NoError(t, err)
+		assert.Len(t, values, 10)
+		for i, v := range values {
+			assert.Equal(t, map[string]interface{}{
+				"name": "panda",
+				"idx":  strconv.Itoa(i),
+			}, v.Values)
+		}
+	}
+
+	// expiry should be set on all keys
+	keys := []string{
+		"myqueue:meta",
+		"myqueue:notifications",
+		"myqueue:s0",
+		"myqueue:s1",
+	}
+
+	for _, key := range keys {
+		ttl, err := rdb.TTL(ctx, key).Result()
+		require.NoError(t, err)
+		assert.Greater(t, ttl, 23*time.Hour)
+	}
+}
+
+func TestClientWriteBalanceGroupIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl)
+	require.NoError(t, client.Prepare(ctx))
+
+	// Stream 0 has many entries, all of which have been acknowledged.
+	for i := range 10 {
+		require.NoError(t, rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: "myqueue:s0",
+			Values: map[string]any{"idx": i},
+		}).Err())
+	}
+	// Stream 1 has a single pending entry.
+	require.NoError(t, rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: "myqueue:s1",
+		Values: map[string]any{"idx": 0},
+	}).Err())
+	for i := range 2 {
+		stream := fmt.Sprintf("myqueue:s%d", i)
+		require.NoError(t, rdb.XGroupCreate(ctx, stream, "mygroup", "0").Err())
+		streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    "mygroup",
+			Consumer: "mygroup:123",
+			Streams:  []string{stream, ">"},
+			Block:    -1,
+		}).Result()
+		require.NoError(t, err)
+		if i == 0 {
+			for _, msg := range streams[0].Messages {
+				require.NoError(t, rdb.XAck(ctx, stream, "mygroup", msg.ID).Err())
+			}
+		}
+	}
+
+	args := func(group string) *queue.WriteArgs {
+		return &queue.WriteArgs{
+			Name:            "myqueue",
+			Streams:         2,
+			StreamsPerShard: 2,
+			ShardKey:        []byte("panda"),
+			Values: map[string]any{
+				"name": "panda",
+			},
+			BalanceGroup: group,
+		}
+	}
+
+	// By default, writes go to the shortest stream.
+	_, err := client.Write(ctx, args(""))
+	require.NoError(t, err)
+	ln, err := rdb.XLen(ctx, "myqueue:s1").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, ln)
+
+	// Balancing by pending count, writes go to the stream with nothing pending.
+	for range 3 {
+		_, err := client.Write(ctx, args("mygroup"))
+		require.NoError(t, err)
+	}
+	ln, err = rdb.XLen(ctx, "myqueue:s0").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 13, ln)
+}
+
+func TestClientWriteMustExistIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl)
+	require.NoError(t, client.Prepare(ctx))
+
+	args := func(mustExist bool) *queue.WriteArgs {
+		return &queue.WriteArgs{
+			Name:            "myqueue",
+			Streams:         2,
+			StreamsPerShard: 1,
+			ShardKey:        []byte("panda"),
+			Values: map[string]any{
+				"name": "panda",
+			},
+			MustExist: mustExist,
+		}
+	}
+
+	_, err := client.Write(ctx, args(true))
+	require.ErrorIs(t, err, queue.ErrQueueMissing)
+
+	// Nothing should have been written
+	n, err := rdb.Exists(ctx, "myqueue:meta", "myqueue:notifications", "myqueue:s0", "myqueue:s1").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+
+	_, err = client.Write(ctx, args(false))
+	require.NoError(t, err)
+
+	_, err = client.Write(ctx, args(true))
+	require.NoError(t, err)
+
+	// "panda" is assigned to shard 1
+	ln, err := rdb.XLen(ctx, "myqueue:s1").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, ln)
+}
+
+func TestClientWriteMaxLenIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+	require.NoError(t, client.Prepare(ctx))
+
+	var ids []string
+	for i := range 5 {
+		id, err := client.Write(ctx, &queue.WriteArgs{
+			Name:     "myqueue",
+			ShardKey: []byte("panda"),
+			Values: map[string]any{
+				"idx": strconv.Itoa(i),
+			},
+			MaxLen: 3,
+		})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	// Only the newest three messages are kept
+	msgs, err := rdb.XRange(ctx, "myqueue:s0", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	for i, msg := range msgs {
+		assert.Equal(t, ids[i+2], msg.ID)
+	}
+
+	// Approximate trimming never trims more than requested
+	for i := range 5 {
+		_, err := client.Write(ctx, &queue.WriteArgs{
+			Name:     "myqueue",
+			ShardKey: []byte("panda"),
+			Values: map[string]any{
+				"idx": strconv.Itoa(i),
+			},
+			MaxLen: 3,
+			Approx: true,
+		})
+		require.NoError(t, err)
+	}
+
+	ln, err := rdb.XLen(ctx, "myqueue:s0").Result()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, ln, int64(3))
+}
+
+func TestClientWriteValidatesMaxLen(t *testing.T) {
+	ctx := test.Context(t)
+	rdb, mock := redismock.NewClientMock()
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+
+	_, err := client.Write(ctx, &queue.WriteArgs{
+		Name:     "myqueue",
+		ShardKey: []byte("panda"),
+		Values:   map[string]any{"name": "panda"},
+		MaxLen:   -1,
+	})
+	require.ErrorIs(t, err, queue.ErrInvalidWriteArgs)
+	// Nothing should have been written
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClientWriteNotificationFailureIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	ttl := 24 * time.Hour
+	args := &queue.WriteArgs{
+		Name:     "myqueue",
+		ShardKey: []byte("panda"),
+		Values: map[string]any{
+			"name": "panda",
+		},
+	}
+
+	// Make the notifications stream unwritable by giving its key the wrong type.
+	require.NoError(t, rdb.Set(ctx, "myqueue:notifications", "oops", 0).Err())
+
+	t.Run("BestEffort", func(t *testing.T) {
+		client := queue.NewClient(rdb, ttl)
+		require.NoError(t, client.Prepare(ctx))
+
+		_, err := client.Write(ctx, args)
+		require.NoError(t, err)
+	})
+
+	t.Run("Required", func(t *testing.T) {
+		client := queue.NewClient(rdb, ttl, queue.WithRequiredNotifications())
+		require.NoError(t, client.Prepare(ctx))
+
+		_, err := client.Write(ctx, args)
+		require.ErrorIs(t, err, queue.ErrNotificationFailed)
+	})
+
+	// The message was written in both cases, and the stream has an expiry.
+	ln, err := rdb.XLen(ctx, "myqueue:s0").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, ln)
+	exp, err := rdb.TTL(ctx, "myqueue:s0").Result()
+	require.NoError(t, err)
+	assert.Equal(t, ttl, exp)
+}
+
+func TestClientTracePropagationIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	orig := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(orig) })
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl, queue.WithTracePropagation())
+	require.NoError(t, client.Prepare(ctx))
+
+	producerCtx, producerSpan := tp.Tracer("test").Start(ctx, "producer")
+	_, err := client.Write(producerCtx, &queue.WriteArgs{
+		Name:     "test",
+		ShardKey: []byte("panda"),
+		Values:   map[string]any{"name": "panda"},
+	})
+	require.NoError(t, err)
+	producerSpan.End()
+
+	msg, err := client.Read(ctx, &queue.ReadArgs{
+		Name:     "test",
+		Group:    "mygroup",
+		Consumer: "mygroup:123",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "panda"}, msg.Values)
+
+	var consumer sdktrace.ReadOnlySpan
+	for _, s := range sr.Ended() {
+		if s.Name() == "queue.read" {
+			consumer = s
+		}
+	}
+	require.NotNil(t, consumer)
+	require.Len(t, consumer.Links(), 1)
+	assert.Equal(t, producerSpan.SpanContext().TraceID(), consumer.Links()[0].SpanContext.TraceID())
+	assert.Equal(t, producerSpan.SpanContext().SpanID(), consumer.Links()[0].SpanContext.SpanID())
+}
+
+func TestClientCompressionIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl, queue.WithCompression("payload"))
+	require.NoError(t, client.Prepare(ctx))
+
+	payload := strings.Repeat(`{"animal": "panda", "diet": "bamboo"}`, 1000)
+	_, err := client.Write(ctx, &queue.WriteArgs{
+		Name:     "test",
+		ShardKey: []byte("panda"),
+		Values:   map[string]any{"payload": payload, "kind": "mammal"},
+	})
+	require.NoError(t, err)
+	// A message written by a client without compression is read unchanged.
+	_, err = queue.NewClient(rdb, ttl).Write(ctx, &queue.WriteArgs{
+		Name:     "test",
+		ShardKey: []byte("panda"),
+		Values:   map[string]any{"payload": "uncompressed", "kind": "fish"},
+	})
+	require.NoError(t, err)
+
+	entries, err := rdb.XRange(ctx, "test:s0", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Less(t, len(entries[0].Values["payload"].(string)), len(payload)/10)
+	assert.Equal(t, "payload", entries[0].Values[queue.CompressedField])
+
+	readArgs := &queue.ReadArgs{
+		Name:     "test",
+		Group:    "mygroup",
+		Consumer: "mygroup:123",
+	}
+	msg, err := client.Read(ctx, readArgs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"payload": payload, "kind": "mammal"}, msg.Values)
+
+	msg, err = client.Read(ctx, readArgs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"payload": "uncompressed", "kind": "fish"}, msg.Values)
+}
+
+func TestClientWriteBatchIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl)
+	require.NoError(t, client.Prepare(ctx))
+
+	args := make([]*queue.WriteArgs, 0, 20)
+	for i := range 20 {
+		name := "queue-a"
+		if i%2 == 1 {
+			name = "queue-b"
+		}
+		args = append(args, &queue.WriteArgs{
+			Name:     name,
+			ShardKey: []byte("panda"),
+			Values: map[string]any{
+				"idx": strconv.Itoa(i),
+			},
+		})
+	}
+
+	ids, err := client.WriteBatch(ctx, args)
+	require.NoError(t, err)
+	require.Len(t, ids, 20)
+
+	for _, name := range []string{"queue-a", "queue-b"} {
+		values, err := rdb.XRange(ctx, name+":s0", "-", "+").Result()
+		require.NoError(t, err)
+		require.Len(t, values, 10)
+
+		for _, v := range values {
+			idx, err := strconv.Atoi(v.Values["idx"].(string))
+			require.NoError(t, err)
+			assert.Equal(t, name, args[idx].Name)
+			assert.Equal(t, v.ID, ids[idx])
+		}
+	}
+}
+
+func TestShardKey(t *testing.T) {
+	assert.Equal(t, queue.ShardKey("acme", "prod"), queue.ShardKey("acme", "prod"))
+
+	assert.NotEqual(t, queue.ShardKey("acme", "prod"), queue.ShardKey("prod", "acme"))
+	assert.NotEqual(t, queue.ShardKey("ab", "c"), queue.ShardKey("a", "bc"))
+	assert.NotEqual(t, queue.ShardKey("a", ""), queue.ShardKey("a"))
+	assert.NotEmpty(t, queue.ShardKey(""))
+}
+
+func TestClientWriteBatchValidatesAllArgs(t *testing.T) {
+	ctx := test.Context(t)
+	rdb, mock := redismock.NewClientMock()
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+
+	ids, err := client.WriteBatch(ctx, []*queue.WriteArgs{
+		{Name: "myqueue", ShardKey: []byte("panda"), Values: map[string]any{"name": "panda"}},
+		{Name: "myqueue", ShardKey: []byte("panda")},
+	})
+	require.ErrorIs(t, err, queue.ErrInvalidWriteArgs)
+	assert.ErrorContains(t, err, "args[1]")
+	assert.Nil(t, ids)
+	// Nothing should have been written
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// redisError is an error reply from the Redis server.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func (redisError) RedisError() {}
+
+func TestClientStatsPerStreamIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+	require.NoError(t, client.Prepare(ctx))
+
+	for i := range 6 {
 		_, err := client.Write(ctx, &queue.WriteArgs{
 			Name:            "myqueue",
-			Streams:         2,
+			Streams:         4,
 			StreamsPerShard: 1,
 			ShardKey:        []byte("panda"),
 			Values: map[string]any{
-				"name": "panda",
-				"idx":  i,
+				"idx": strconv.Itoa(i),
 			},
 		})
 		require.NoError(t, err)
 	}
 
-	for i := range 5 {
-		_, err := client.Write(ctx, &queue.WriteArgs{
+	var msg *queue.Message
+	for range 2 {
+		var err error
+		msg, err = client.Read(ctx, &queue.ReadArgs{
+			Name:     "myqueue",
+			Group:    "mygroup",
+			Consumer: "mygroup:123",
+		})
+		require.NoError(t, err)
+	}
+
+	stats, err := client.StatsPerStream(ctx, "myqueue", "mygroup")
+	require.NoError(t, err)
+	require.Len(t, stats, 4)
+
+	for i, s := range stats {
+		assert.Equal(t, fmt.Sprintf("myqueue:s%d", i), s.StreamID)
+		if s.StreamID == msg.Stream {
+			// All writes for a single-stream shard go to the same stream.
+			assert.EqualValues(t, 6, s.Len)
+			assert.EqualValues(t, 2, s.PendingCount)
+		} else {
+			assert.Zero(t, s.Len)
+			assert.Zero(t, s.PendingCount)
+		}
+	}
+}
+
+func TestClientPeek(t *testing.T) {
+	ctx := test.Context(t)
+	_, rdb := test.MiniRedis(t)
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+	require.NoError(t, client.Prepare(ctx))
+
+	ids := make(map[string]string)
+	for _, name := range []string{"panda", "giraffe", "elephant", "zebra"} {
+		id, err := client.Write(ctx, &queue.WriteArgs{
 			Name:            "myqueue",
 			Streams:         2,
-			StreamsPerShard: 1,
-			ShardKey:        []byte("giraffe"),
+			StreamsPerShard: 2,
+			ShardKey:        []byte("panda"),
 			Values: map[string]any{
-				"name": "giraffe",
-				"idx":  i,
+				"name": name,
 			},
 		})
 		require.NoError(t, err)
+		ids[name] = id
 	}
 
-	// meta key contains the number of streams
-	val, err := rdb.HGet(ctx, "myqueue:meta", "streams").Result()
+	msgs, err := client.Peek(ctx, "myqueue", 1)
 	require.NoError(t, err)
-	assert.Equal(t, "2", val)
-	// notification stream contains at least one message
-	{
-		ln, err := rdb.XLen(ctx, "myqueue:notifications").Result()
-		require.NoError(t, err)
-		assert.Greater(t, ln, int64(0))
+	require.Len(t, msgs, 2)
+	for _, msg := range msgs {
+		assert.Equal(t, ids[msg.Values["name"].(string)], msg.ID)
+		assert.Contains(t, []string{"myqueue:s0", "myqueue:s1"}, msg.Stream)
 	}
+	assert.NotEqual(t, msgs[0].Stream, msgs[1].Stream)
 
-	// "giraffe" is assigned to shard 0
-	{
-		l0, err := rdb.XLen(ctx, "myqueue:s0").Result()
+	msgs, err = client.Peek(ctx, "myqueue", 10)
+	require.NoError(t, err)
+	assert.Len(t, msgs, 4)
+
+	// Peeking doesn't create or modify any consumer groups.
+	for _, stream := range []string{"myqueue:s0", "myqueue:s1"} {
+		groups, err := rdb.XInfoGroups(ctx, stream).Result()
 		require.NoError(t, err)
-		assert.EqualValues(t, 5, l0)
+		assert.Empty(t, groups)
+	}
 
-		values, err := rdb.XRange(ctx, "myqueue:s0", "-", "+").Result()
+	_, err = client.Peek(ctx, "myqueue", 0)
+	assert.ErrorIs(t, err, queue.ErrInvalidReadArgs)
+}
+
+func TestClientScriptsLoaded(t *testing.T) {
+	ctx := test.Context(t)
+	_, rdb := test.MiniRedis(t)
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+	require.NoError(t, client.Prepare(ctx))
+
+	loaded, err := client.ScriptsLoaded(ctx)
+	require.NoError(t, err)
+	assert.Len(t, loaded, 7)
+	for name, ok := range loaded {
+		assert.True(t, ok, name)
+	}
+
+	require.NoError(t, rdb.ScriptFlush(ctx).Err())
+	loaded, err = client.ScriptsLoaded(ctx)
+	require.NoError(t, err)
+	assert.Len(t, loaded, 7)
+	for name, ok := range loaded {
+		assert.False(t, ok, name)
+	}
+}
+
+func TestClientWriteReloadsScriptsOnNoScript(t *testing.T) {
+	ctx := test.Context(t)
+	rdb, mock := redismock.NewClientMock()
+	mock.MatchExpectationsInOrder(true)
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+
+	noscript := redisError("NOSCRIPT No matching script. Please use EVAL.")
+	// seconds, streams, mustexist, notify, maxlen, approx, group, n, sid, field, value
+	args := []any{86400, 1, 0, 0, int64(0), 0, "", 1, 0, "name", "panda"}
+
+	// The script cache has been flushed, and the EVAL fallback also fails (as
+	// happens behind some proxies).
+	mock.Regexp().ExpectEvalSha(`.*`, []string{"myqueue"}, args...).SetErr(noscript)
+	mock.Regexp().ExpectEval(`.*`, []string{"myqueue"}, args...).SetErr(noscript)
+	// All the scripts are reloaded...
+	for range 7 {
+		mock.Regexp().ExpectScriptLoad(`.*`).SetVal("ok")
+	}
+	// ...and the write is retried.
+	mock.Regexp().ExpectEvalSha(`.*`, []string{"myqueue"}, args...).SetVal("1-0")
+
+	id, err := client.Write(ctx, &queue.WriteArgs{
+		Name:     "myqueue",
+		ShardKey: []byte("panda"),
+		Values:   map[string]any{"name": "panda"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1-0", id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClientAck(t *testing.T) {
+	ctx := test.Context(t)
+	rdb, mock := redismock.NewClientMock()
+	client := queue.NewClient(rdb, 24*time.Hour)
+
+	// Messages on different streams may have the same ID, and must only be
+	// acknowledged on their own stream.
+	mock.ExpectXAck("myqueue:s1", "mygroup", "1-0", "2-0").SetVal(2)
+	mock.ExpectXAck("myqueue:s0", "mygroup", "1-0").SetVal(1)
+
+	require.NoError(t, client.Ack(ctx, "mygroup",
+		&queue.Message{Stream: "myqueue:s1", ID: "1-0"},
+		&queue.Message{Stream: "myqueue:s0", ID: "1-0"},
+		&queue.Message{Stream: "myqueue:s1", ID: "2-0"},
+	))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	err := client.Ack(ctx, "mygroup", &queue.Message{ID: "1-0"})
+	assert.ErrorIs(t, err, queue.ErrInvalidReadArgs)
+}
+
+func TestClientAckMessageIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+	require.NoError(t, client.Prepare(ctx))
+
+	for i := range 4 {
+		_, err := client.Write(ctx, &queue.WriteArgs{
+			Name:            "myqueue",
+			Streams:         2,
+			StreamsPerShard: 2,
+			ShardKey:        []byte("panda"),
+			Values: map[string]any{
+				"idx": strconv.Itoa(i),
+			},
+		})
 		require.NoError(t, err)
-		assert.Len(t, values, 5)
-		for i, v := range values {
-			assert.Equal(t, map[string]interface{}{
-				"name": "giraffe",
-				"idx":  strconv.Itoa(i),
-			}, v.Values)
-		}
 	}
 
-	// "panda" is assigned to shard 1
-	{
-		l1, err := rdb.XLen(ctx, "myqueue:s1").Result()
+	readArgs := &queue.ReadArgs{
+		Name:     "myqueue",
+		Group:    "mygroup",
+		Consumer: "mygroup:123",
+	}
+	msgs := make([]*queue.Message, 0, 4)
+	for range 4 {
+		msg, err := client.Read(ctx, readArgs)
 		require.NoError(t, err)
-		assert.EqualValues(t, 10, l1)
+		msgs = append(msgs, msg)
+	}
 
-		values, err := rdb.XRange(ctx, "myqueue:s1", "-", "+").Result()
+	stats, err := client.Stats(ctx, "myqueue", "mygroup")
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, stats.PendingCount)
+
+	require.NoError(t, client.AckMessage(ctx, "mygroup", msgs[0]))
+	require.NoError(t, client.Ack(ctx, "mygroup", msgs[1], msgs[2]))
+
+	stats, err = client.Stats(ctx, "myqueue", "mygroup")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, stats.PendingCount)
+}
+
+func TestClientDrainIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+	require.NoError(t, client.Prepare(ctx))
+
+	for i := range 6 {
+		_, err := client.Write(ctx, &queue.WriteArgs{
+			Name:     "myqueue",
+			ShardKey: []byte("panda"),
+			Values: map[string]any{
+				"idx": strconv.Itoa(i),
+			},
+		})
 		require.NoError(t, err)
-		assert.Len(t, values, 10)
-		for i, v := range values {
-			assert.Equal(t, map[string]interface{}{
-				"name": "panda",
-				"idx":  strconv.Itoa(i),
-			}, v.Values)
+	}
+
+	boom := errors.New("boom")
+	var handled []string
+	err := client.Drain(ctx, "myqueue", "mygroup", "mygroup:123", func(msg *queue.Message) error {
+		idx := msg.Values["idx"].(string)
+		handled = append(handled, idx)
+		if idx == "3" {
+			return boom
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, boom)
+	assert.Equal(t, []string{"0", "1", "2", "3", "4", "5"}, handled)
+
+	// Only the message which failed is left pending
+	pending, err := rdb.XPending(ctx, "myqueue:s0", "mygroup").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, pending.Count)
+
+	// Once drained, there's nothing left to handle
+	err = client.Drain(ctx, "myqueue", "mygroup", "mygroup:123", func(msg *queue.Message) error {
+		t.Errorf("unexpected message %s", msg.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestClientDrainStopsWhenContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(test.Context(t))
+	rdb, mock := redismock.NewClientMock()
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+
+	cancel()
+	err := client.Drain(ctx, "myqueue", "mygroup", "mygroup:123", func(*queue.Message) error {
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	// Nothing should have been read
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	err = client.Drain(test.Context(t), "myqueue", "mygroup", "mygroup:123", nil)
+	assert.ErrorIs(t, err, queue.ErrInvalidReadArgs)
+}
+
+func TestClientReclaim(t *testing.T) {
+	ctx := test.Context(t)
+	rdb, mock := redismock.NewClientMock()
+	client := queue.NewClient(rdb, 24*time.Hour)
+
+	claimArgs := func(stream, start string, count int64) *redis.XAutoClaimArgs {
+		return &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    "mygroup",
+			Consumer: "mygroup:456",
+			MinIdle:  time.Minute,
+			Start:    start,
+			Count:    count,
 		}
 	}
 
-	// expiry should be set on all keys
-	keys := []string{
-		"myqueue:meta",
-		"myqueue:notifications",
-		"myqueue:s0",
-		"myqueue:s1",
+	mock.ExpectHGet("myqueue:meta", "streams").SetVal("3")
+	mock.ExpectXAutoClaim(claimArgs("myqueue:s0", "0-0", 3)).SetVal([]redis.XMessage{
+		{ID: "1-0", Values: map[string]any{"name": "panda"}},
+		{ID: "2-0"}, // deleted from the stream
+	}, "3-0")
+	mock.ExpectXAutoClaim(claimArgs("myqueue:s0", "3-0", 2)).SetVal([]redis.XMessage{}, "0-0")
+	mock.ExpectXAutoClaim(claimArgs("myqueue:s1", "0-0", 2)).SetErr(redisError("NOGROUP No such key 'myqueue:s1' or consumer group 'mygroup'"))
+	mock.ExpectXAutoClaim(claimArgs("myqueue:s2", "0-0", 2)).SetVal([]redis.XMessage{
+		{ID: "4-0", Values: map[string]any{"name": "giraffe"}},
+		{ID: "5-0", Values: map[string]any{"name": "elephant"}},
+	}, "6-0")
+
+	msgs, err := client.Reclaim(ctx, &queue.ReclaimArgs{
+		Name:     "myqueue",
+		Group:    "mygroup",
+		Consumer: "mygroup:456",
+		MinIdle:  time.Minute,
+		Count:    3,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*queue.Message{
+		{Stream: "myqueue:s0", ID: "1-0", Values: map[string]any{"name": "panda"}},
+		{Stream: "myqueue:s2", ID: "4-0", Values: map[string]any{"name": "giraffe"}},
+		{Stream: "myqueue:s2", ID: "5-0", Values: map[string]any{"name": "elephant"}},
+	}, msgs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClientReclaimIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	client := queue.NewClient(rdb, 24*time.Hour)
+	require.NoError(t, client.Prepare(ctx))
+
+	for i := range 4 {
+		_, err := client.Write(ctx, &queue.WriteArgs{
+			Name:            "myqueue",
+			Streams:         2,
+			StreamsPerShard: 2,
+			ShardKey:        []byte("panda"),
+			Values: map[string]any{
+				"idx": strconv.Itoa(i),
+			},
+		})
+		require.NoError(t, err)
 	}
 
-	for _, key := range keys {
-		ttl, err := rdb.TTL(ctx, key).Result()
+	// A consumer reads all the messages and then dies.
+	delivered := make([]string, 0, 4)
+	for range 4 {
+		msg, err := client.Read(ctx, &queue.ReadArgs{
+			Name:     "myqueue",
+			Group:    "mygroup",
+			Consumer: "mygroup:123",
+		})
 		require.NoError(t, err)
-		assert.Greater(t, ttl, 23*time.Hour)
+		delivered = append(delivered, msg.ID)
+	}
+
+	reclaimArgs := &queue.ReclaimArgs{
+		Name:     "myqueue",
+		Group:    "mygroup",
+		Consumer: "mygroup:456",
+		MinIdle:  time.Hour,
+	}
+
+	// Nothing has been idle for long enough.
+	msgs, err := client.Reclaim(ctx, reclaimArgs)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+
+	reclaimArgs.MinIdle = 0
+	msgs, err = client.Reclaim(ctx, reclaimArgs)
+	require.NoError(t, err)
+	reclaimed := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		reclaimed = append(reclaimed, msg.ID)
+		require.NoError(t, client.AckMessage(ctx, "mygroup", msg))
 	}
+	assert.ElementsMatch(t, delivered, reclaimed)
+}
+
+func TestClientPruneConsumers(t *testing.T) {
+	ctx := test.Context(t)
+	rdb, mock := redismock.NewClientMock()
+	client := queue.NewClient(rdb, 24*time.Hour)
+
+	mock.ExpectHGet("myqueue:meta", "streams").SetVal("2")
+	mock.ExpectXInfoConsumers("myqueue:s0", "mygroup").SetVal([]redis.XInfoConsumer{
+		{Name: "idle", Pending: 0, Idle: time.Hour},
+		{Name: "busy", Pending: 1, Idle: time.Hour},
+		{Name: "active", Pending: 0, Idle: time.Second},
+	})
+	mock.ExpectXGroupDelConsumer("myqueue:s0", "mygroup", "idle").SetVal(0)
+	mock.ExpectXInfoConsumers("myqueue:s1", "mygroup").SetErr(redisError("NOGROUP No such consumer group 'mygroup' for key name 'myqueue:s1'"))
+	mock.ExpectXInfoConsumers("myqueue:notifications", "mygroup").SetVal([]redis.XInfoConsumer{
+		{Name: "idle", Pending: 0, Idle: time.Hour},
+	})
+	mock.ExpectXGroupDelConsumer("myqueue:notifications", "mygroup", "idle").SetVal(0)
+
+	n, err := client.PruneConsumers(ctx, "myqueue", "mygroup", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // TestPickupLatencyIntegration runs a test with a mostly-empty queue -- by
@@ -505,3 +1542,49 @@ func BenchmarkWrite(b *testing.B) {
 	b.Run("16-4", func(b *testing.B) { benchmarkWrite(16, 4, b) })
 	b.Run("64-6", func(b *testing.B) { benchmarkWrite(64, 6, b) })
 }
+
+func benchmarkWriteBatch(batch int, b *testing.B) {
+	ctx := test.Context(b)
+	rdb := test.Redis(ctx, b)
+
+	ttl := 24 * time.Hour
+	client := queue.NewClient(rdb, ttl)
+	require.NoError(b, client.Prepare(ctx))
+
+	key := make([]byte, 16)
+	_, _ = crand.Read(key)
+
+	newArgs := func() []*queue.WriteArgs {
+		args := make([]*queue.WriteArgs, batch)
+		for i := range args {
+			args[i] = &queue.WriteArgs{
+				Name:            "testbench",
+				Streams:         16,
+				StreamsPerShard: 4,
+				ShardKey:        key,
+				Values:          map[string]any{"id": time.Now().UnixNano()},
+			}
+		}
+		return args
+	}
+
+	b.Run("Write", func(b *testing.B) {
+		for range b.N {
+			for _, args := range newArgs() {
+				_, err := client.Write(ctx, args)
+				require.NoError(b, err)
+			}
+		}
+	})
+	b.Run("WriteBatch", func(b *testing.B) {
+		for range b.N {
+			_, err := client.WriteBatch(ctx, newArgs())
+			require.NoError(b, err)
+		}
+	})
+}
+
+func BenchmarkWriteBatch(b *testing.B) {
+	b.Run("10", func(b *testing.B) { benchmarkWriteBatch(10, b) })
+	b.Run("100", func(b *testing.B) { benchmarkWriteBatch(100, b) })
+}
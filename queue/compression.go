@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// CompressedField is the message value which records the name of the value
+// compressed by a client configured with WithCompression.
+const CompressedField = "__compressed"
+
+// compress returns v gzip-compressed, if it is a string or []byte.
+func compress(v any) ([]byte, bool) {
+	var data []byte
+	switch v := v.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return nil, false
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// decompress replaces the value named by the message's CompressedField, if
+// any, with its decompressed contents, and removes the marker.
+func decompress(msg *Message) error {
+	v, ok := msg.Values[CompressedField]
+	if !ok {
+		return nil
+	}
+	delete(msg.Values, CompressedField)
+
+	field, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("queue: unexpected type %T for compressed field name", v)
+	}
+	compressed, ok := msg.Values[field].(string)
+	if !ok {
+		return fmt.Errorf("queue: unexpected type %T for compressed value %s", msg.Values[field], field)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader([]byte(compressed)))
+	if err != nil {
+		return fmt.Errorf("queue: error decompressing value %s: %w", field, err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("queue: error decompressing value %s: %w", field, err)
+	}
+	msg.Values[field] = string(data)
+	return nil
+}
@@ -0,0 +1,49 @@
+-- oldestpending commands take the form
+--
+--   EVALSHA sha 1 key group
+--
+-- The result is the ID of the oldest pending entry across all the streams in
+-- the queue, or nil if there are no pending entries.
+--
+-- Note: strictly, it is illegal for a script to manipulate keys that are not
+-- explicitly passed to EVAL{,SHA}, but in practice this is fine as long as all
+-- keys are on the same server (e.g. in cluster scenarios). In our case a single
+-- queue, which may be composed of multiple streams and metadata keys, is always
+-- on the same server.
+
+local base = KEYS[1]
+local group = ARGV[1]
+
+local key_meta = base .. ':meta'
+
+local streams = tonumber(redis.call('HGET', key_meta, 'streams') or 1)
+
+local function parseid (id)
+  local ms, seq = string.match(id, '^(%d+)-(%d+)$')
+  return tonumber(ms, 10), tonumber(seq, 10)
+end
+
+local oldest = false
+local oldest_ms, oldest_seq
+
+for idx = 0, streams-1 do
+  local stream = base .. ':s' .. idx
+
+  local info = redis.pcall('XPENDING', stream, group)
+  if info['err'] then
+    if string.match(info['err'], '^NOGROUP ') then
+      -- if either the stream or group don't exist, there are no pending entries
+    else
+      return redis.error_reply(info['err']..' accessing '..stream)
+    end
+  elseif info[1] > 0 then
+    local ms, seq = parseid(info[2])
+    if not oldest or ms < oldest_ms or (ms == oldest_ms and seq < oldest_seq) then
+      oldest = info[2]
+      oldest_ms = ms
+      oldest_seq = seq
+    end
+  end
+end
+
+return oldest
@@ -0,0 +1,54 @@
+package queue
+
+type Option interface {
+	apply(*clientOptions)
+}
+
+type clientOptions struct {
+	PropagateTraceContext bool
+	CompressedField       string
+	RequireNotifications  bool
+}
+
+type optionFunc func(*clientOptions)
+
+func (fn optionFunc) apply(opts *clientOptions) {
+	fn(opts)
+}
+
+// WithTracePropagation configures the client to store the trace context of the
+// writer in each message it writes, in the TraceContextField value. When the
+// message is read, the span for the read is linked to the writer's span, so
+// that a message can be traced from producer to consumer.
+//
+// This is opt-in because it adds a value to every message written.
+func WithTracePropagation() Option {
+	return optionFunc(func(opts *clientOptions) {
+		opts.PropagateTraceContext = true
+	})
+}
+
+// WithCompression configures the client to gzip-compress the message value
+// named field when writing messages, if it is a string or []byte. Compressed
+// messages are marked so that they are transparently decompressed by Read
+// (regardless of how the reading client is configured), and messages written
+// without compression continue to be read as-is.
+func WithCompression(field string) Option {
+	return optionFunc(func(opts *clientOptions) {
+		opts.CompressedField = field
+	})
+}
+
+// WithRequiredNotifications configures the client to fail writes with
+// ErrNotificationFailed if the message was written but the notification which
+// wakes blocked consumers could not be. By default notification is
+// best-effort: a consumer which misses a notification still picks up the
+// message when it next polls, but with increased latency.
+//
+// Note that the message has been written even when ErrNotificationFailed is
+// returned, so a caller which retries the write may deliver it more than once.
+func WithRequiredNotifications() Option {
+	return optionFunc(func(opts *clientOptions) {
+		opts.RequireNotifications = true
+	})
+}
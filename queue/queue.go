@@ -44,6 +44,10 @@ var (
 	lenCmd    string
 	lenScript = redis.NewScript(lenCmd)
 
+	//go:embed oldestpending.lua
+	oldestPendingCmd    string
+	oldestPendingScript = redis.NewScript(oldestPendingCmd)
+
 	//go:embed pendingcount.lua
 	pendingCountCmd    string
 	pendingCountScript = redis.NewScript(pendingCountCmd)
@@ -52,6 +56,10 @@ var (
 	statsCmd    string
 	statsScript = redis.NewScript(statsCmd)
 
+	//go:embed statsperstream.lua
+	statsPerStreamCmd    string
+	statsPerStreamScript = redis.NewScript(statsPerStreamCmd)
+
 	//go:embed read.lua
 	readCmd    string
 	readScript = redis.NewScript(readCmd)
@@ -61,16 +69,36 @@ var (
 	writeScript = redis.NewScript(writeCmd)
 )
 
+// scripts lists the queue's scripts by name, for diagnostics.
+var scripts = []struct {
+	name   string
+	script *redis.Script
+}{
+	{"len", lenScript},
+	{"oldestpending", oldestPendingScript},
+	{"pendingcount", pendingCountScript},
+	{"stats", statsScript},
+	{"statsperstream", statsPerStreamScript},
+	{"read", readScript},
+	{"write", writeScript},
+}
+
 func prepare(ctx context.Context, rdb redis.Cmdable) error {
 	if err := lenScript.Load(ctx, rdb).Err(); err != nil {
 		return err
 	}
+	if err := oldestPendingScript.Load(ctx, rdb).Err(); err != nil {
+		return err
+	}
 	if err := pendingCountScript.Load(ctx, rdb).Err(); err != nil {
 		return err
 	}
 	if err := statsScript.Load(ctx, rdb).Err(); err != nil {
 		return err
 	}
+	if err := statsPerStreamScript.Load(ctx, rdb).Err(); err != nil {
+		return err
+	}
 	if err := readScript.Load(ctx, rdb).Err(); err != nil {
 		return err
 	}
@@ -79,3 +107,19 @@ func prepare(ctx context.Context, rdb redis.Cmdable) error {
 	}
 	return nil
 }
+
+func scriptsLoaded(ctx context.Context, rdb redis.Cmdable) (map[string]bool, error) {
+	hashes := make([]string, len(scripts))
+	for i, s := range scripts {
+		hashes[i] = s.script.Hash()
+	}
+	exists, err := rdb.ScriptExists(ctx, hashes...).Result()
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[string]bool, len(scripts))
+	for i, s := range scripts {
+		loaded[s.name] = exists[i]
+	}
+	return loaded, nil
+}
@@ -0,0 +1,42 @@
+-- statsperstream commands take the form
+--
+--   EVALSHA sha 1 key group
+--
+-- and return a flat array of {len, pending_count} pairs, one for each stream in
+-- the queue in order.
+--
+-- Note: strictly, it is illegal for a script to manipulate keys that are not
+-- explicitly passed to EVAL{,SHA}, but in practice this is fine as long as all
+-- keys are on the same server (e.g. in cluster scenarios). In our case a single
+-- queue, which may be composed of multiple streams and metadata keys, is always
+-- on the same server.
+
+local base = KEYS[1]
+local group = ARGV[1]
+
+local key_meta = base .. ':meta'
+
+local streams = tonumber(redis.call('HGET', key_meta, 'streams') or 1)
+local result = {}
+
+for idx = 0, streams-1 do
+  local stream = base .. ':s' .. idx
+
+  local len = redis.call('XLEN', stream)
+  local pending_count = 0
+  local info = redis.pcall('XPENDING', stream, group)
+  if info['err'] then
+    if string.match(info['err'], '^NOGROUP ') then
+      -- if either the stream or group don't exist, there are zero pending entries
+    else
+      return redis.error_reply(info['err']..' accessing '..stream)
+    end
+  else
+    pending_count = info[1]
+  end
+
+  table.insert(result, len)
+  table.insert(result, pending_count)
+end
+
+return result
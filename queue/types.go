@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"encoding/binary"
 	"time"
 )
 
@@ -20,7 +21,48 @@ type WriteArgs struct {
 
 	Streams         int    // total number of streams
 	StreamsPerShard int    // number of streams in each shard
-	ShardKey        []byte // tenant key to determine shard
+	ShardKey        []byte // tenant key to determine shard (see ShardKey)
+
+	// If MustExist is set, the write will fail with ErrQueueMissing rather
+	// than implicitly creating the queue if it does not already exist (for
+	// example because its keys have expired).
+	MustExist bool
+
+	// If BalanceGroup is set, the message is written to the stream in the shard
+	// with the fewest entries pending (delivered but unacknowledged) for that
+	// consumer group, rather than the shortest stream. This avoids penalizing
+	// streams which contain many acknowledged entries that haven't yet been
+	// trimmed, at the cost of an XPENDING call per stream in the shard.
+	//
+	// Note that entries which haven't yet been delivered to the group are not
+	// pending, so this is only a good measure of load when consumers are
+	// keeping up with the queue.
+	BalanceGroup string
+
+	// If MaxLen is non-zero, the selected stream is trimmed to at most MaxLen
+	// entries as the message is added (XADD ... MAXLEN), discarding the oldest
+	// entries whether or not they have been read. If Approx is also set, the
+	// stream is trimmed approximately (MAXLEN ~), which is much more efficient
+	// but may leave the stream somewhat longer than MaxLen.
+	MaxLen int64
+	Approx bool
+}
+
+// ShardKey builds a shard key for WriteArgs from one or more parts (e.g. tenant
+// identifiers). Each part is prefixed with its length, so the result is the
+// same for the same parts in the same order, and different parts never produce
+// the same key by concatenation (e.g. "ab", "c" and "a", "bc" differ).
+func ShardKey(parts ...string) []byte {
+	n := 0
+	for _, p := range parts {
+		n += binary.MaxVarintLen64 + len(p)
+	}
+	key := make([]byte, 0, n)
+	for _, p := range parts {
+		key = binary.AppendUvarint(key, uint64(len(p)))
+		key = append(key, p...)
+	}
+	return key
 }
 
 type ReadArgs struct {
@@ -29,6 +71,22 @@ type ReadArgs struct {
 	Consumer     string        // consumer ID
 	PreferStream string        // if specified, prefer reading from this stream
 	Block        time.Duration // total blocking time
+
+	// If RecoverPending is set, messages previously delivered to this consumer
+	// which have not been acknowledged are redelivered before any new messages
+	// are read. This is typically used when a consumer restarts.
+	RecoverPending bool
+}
+
+type ReclaimArgs struct {
+	Name     string        // queue name
+	Group    string        // consumer group name
+	Consumer string        // consumer to which reclaimed messages are assigned
+	MinIdle  time.Duration // minimum time since a message was last delivered
+
+	// Count limits the number of messages reclaimed by a single call. If it is
+	// zero, all eligible messages are reclaimed.
+	Count int
 }
 
 type Message struct {
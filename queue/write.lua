@@ -1,6 +1,6 @@
 -- Write commands take the form
 --
---   EVALSHA sha 1 key seconds streams n sid [sid ...] field value [field value ...]
+--   EVALSHA sha 1 key seconds streams mustexist notify maxlen approx group n sid [sid ...] field value [field value ...]
 --
 -- - `key` is the base key for the queue, e.g. "prediction:input:abcd1234"
 -- - `seconds` determines the expiry timeout for all keys that make up the
@@ -8,11 +8,23 @@
 -- - `streams` is the number of streams the queue should have. In reality, the
 --   queue may temporarily have more streams, if `streams` was previously larger
 --   and the queue is in the process of resizing.
+-- - `mustexist` is 1 if the write should fail (rather than implicitly creating
+--   the queue) when the queue does not already exist, and 0 otherwise.
+-- - `notify` is 1 if the write should fail if the notifications stream cannot
+--   be written to, and 0 if notification is best-effort. Note that the message
+--   itself will have been written in either case.
+-- - `maxlen` is the maximum length to trim the selected stream to when adding
+--   the message, or 0 if the stream should not be trimmed.
+-- - `approx` is 1 if trimming may be approximate (`MAXLEN ~`), which is much
+--   more efficient, and 0 if the stream should be trimmed to exactly `maxlen`.
+-- - `group` is the name of a consumer group. If non-empty, the message will be
+--   written to the selected stream with the fewest pending entries for that
+--   group, rather than the shortest. Otherwise it must be "".
 -- - `n` is the number of streams this write will consider. It must be less than
 --   or equal to `streams`.
 -- - `sid` are the stream IDs to consider writing to. They must be in the range
---   [0, `streams`). The message will be written to the shortest of the selected
---   streams.
+--   [0, `streams`). The message will be written to the shortest (or least
+--   loaded, see `group`) of the selected streams.
 --
 -- Note: strictly, it is illegal for a script to manipulate keys that are not
 -- explicitly passed to EVAL{,SHA}, but in practice this is fine as long as all
@@ -23,9 +35,14 @@
 local base = KEYS[1]
 local ttl = tonumber(ARGV[1], 10)
 local writestreams = tonumber(ARGV[2], 10)
-local n = tonumber(ARGV[3], 10)
-local sids = {unpack(ARGV, 4, 4 + n - 1)}
-local fields = {unpack(ARGV, 4 + n, #ARGV)}
+local mustexist = tonumber(ARGV[3], 10)
+local notify = tonumber(ARGV[4], 10)
+local maxlen = tonumber(ARGV[5], 10)
+local approx = tonumber(ARGV[6], 10)
+local group = ARGV[7]
+local n = tonumber(ARGV[8], 10)
+local sids = {unpack(ARGV, 9, 9 + n - 1)}
+local fields = {unpack(ARGV, 9 + n, #ARGV)}
 
 local key_meta = base .. ':meta'
 local key_notifications = base .. ':notifications'
@@ -49,6 +66,15 @@ for i = 1, n do
   end
 end
 
+-- Refuse to recreate a queue whose keys have expired if the caller has asserted
+-- that it must already exist. Any live queue has either a meta key or a
+-- notifications stream: the meta key is not written for single-stream queues
+-- until they are read from. Individual streams within an existing queue are
+-- still created as needed, as they are written lazily as shards are used.
+if mustexist == 1 and redis.call('EXISTS', key_meta, key_notifications) == 0 then
+  return redis.error_reply('QUEUEMISSING queue does not exist')
+end
+
 -- How many streams are currently active?
 local readstreams = tonumber(redis.call('HGET', key_meta, 'streams') or 1)
 
@@ -72,14 +98,34 @@ if update and readstreams ~= writestreams then
   redis.call('HSET', key_meta, 'streams', writestreams)
 end
 
--- Find the shortest stream
+-- The load on a stream is its length or, if a group was given, the number of
+-- entries pending for that group. Entries which have been acknowledged but not
+-- yet deleted or trimmed count towards the length, but not the pending count.
+--
+-- If the group doesn't exist on the stream, nothing has been read from it, so
+-- all its entries are outstanding and we fall back to the length.
+local function load (key)
+  if group ~= '' then
+    local info = redis.pcall('XPENDING', key, group)
+    if info['err'] then
+      if not string.match(info['err'], '^NOGROUP ') then
+        error(redis.error_reply(info['err']..' accessing '..key))
+      end
+    else
+      return info[1]
+    end
+  end
+  return redis.call('XLEN', key)
+end
+
+-- Find the least loaded stream
 local selected_sid = sids[1]
 
 if n > 1 then
   local len = -1
   for i = 1, n do
     local key = base .. ':s' .. sids[i]
-    local xlen = redis.call('XLEN', key)
+    local xlen = load(key)
 
     -- It doesn't get shorter than empty
     if xlen == 0 then
@@ -97,14 +143,29 @@ end
 
 -- Add the message to the selected stream
 local key_stream = base .. ':s' .. selected_sid
-local id = redis.call('XADD', key_stream, '*', unpack(fields))
-
--- Add a notification to the notifications stream
-redis.call('XADD', key_notifications, 'MAXLEN', '1', '*', 's', selected_sid)
+local id
+if maxlen > 0 and approx == 1 then
+  id = redis.call('XADD', key_stream, 'MAXLEN', '~', maxlen, '*', unpack(fields))
+elseif maxlen > 0 then
+  id = redis.call('XADD', key_stream, 'MAXLEN', maxlen, '*', unpack(fields))
+else
+  id = redis.call('XADD', key_stream, '*', unpack(fields))
+end
 
--- Set expiry on selected stream + meta/notifications keys
+-- Set expiry on selected stream + meta keys
 redis.call('EXPIRE', key_stream, ttl)
 redis.call('EXPIRE', key_meta, ttl)
+
+-- Add a notification to the notifications stream. Blocked consumers will still
+-- find the message when they next poll if this fails, so unless the caller has
+-- asked otherwise we don't fail the write.
+local notified = redis.pcall('XADD', key_notifications, 'MAXLEN', '1', '*', 's', selected_sid)
 redis.call('EXPIRE', key_notifications, ttl)
 
+-- On success XADD returns the ID of the notification as a string.
+if notify == 1 and type(notified) ~= 'string' then
+  local err = type(notified) == 'table' and notified['err'] or 'unknown error'
+  return redis.error_reply('NOTIFYFAILED '..err..' (message '..id..' was written)')
+end
+
 return id
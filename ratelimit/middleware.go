@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/replicate/go/logging"
+)
+
+var logger = logging.New("ratelimit")
+
+// Middleware returns HTTP middleware which takes one token per request from
+// the token bucket named by keyFunc, using the given rate and capacity. The
+// number of tokens remaining is returned in the X-RateLimit-Remaining header.
+//
+// If no token is available, the request is rejected with a 429 Too Many
+// Requests response, with a Retry-After header set to the time (in whole
+// seconds, and at least one) until the next token is available.
+//
+// If the limiter returns an error, the request is rejected with a 503 Service
+// Unavailable response. Configure the limiter WithFailOpen to allow requests
+// through instead when the Redis server cannot be reached.
+func Middleware(limiter Limiter, keyFunc func(*http.Request) string, rate, capacity int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			result, err := limiter.Take(ctx, keyFunc(r), 1, rate, capacity)
+			if err != nil {
+				logger.With(logging.GetFields(ctx)...).Error("rate limiter failed: rejecting request", zap.Error(err))
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+
+			if !result.Degraded {
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			}
+
+			if !result.OK {
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter(rate)))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// retryAfter returns the time in whole seconds until a bucket refilling at rate
+// has accrued one token, and at least one second.
+func retryAfter(rate int) int {
+	if rate <= 0 {
+		// The bucket never refills, so there is no sensible time to suggest.
+		return 1
+	}
+	return max(int(math.Ceil(1/float64(rate))), 1)
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/replicate/go/test"
+)
+
+func TestMiddleware(t *testing.T) {
+	ctx := test.Context(t)
+
+	_, rdb := test.MiniRedis(t)
+	limiter, err := NewLimiter(rdb)
+	require.NoError(t, err)
+	require.NoError(t, limiter.Prepare(ctx))
+
+	keyFunc := func(r *http.Request) string {
+		return "limit:" + r.Header.Get("X-User")
+	}
+	handler := Middleware(limiter, keyFunc, 1, 2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The bucket starts full, with a capacity of 2
+	rec := do("alice")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+
+	rec = do("alice")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+
+	rec = do("alice")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	// Other keys are unaffected
+	rec = do("bob")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+}
+
+func TestMiddlewareRejectsRequestsOnError(t *testing.T) {
+	ctx := test.Context(t)
+
+	// Nothing is listening on this address
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	limiter, err := NewLimiter(rdb)
+	require.NoError(t, err)
+
+	called := false
+	handler := Middleware(limiter, func(*http.Request) string { return "limit:key" }, 1, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.False(t, called)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-RateLimit-Remaining"))
+}
+
+func TestMiddlewareFailOpenAllowsRequestsOnError(t *testing.T) {
+	ctx := test.Context(t)
+
+	// Nothing is listening on this address
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	limiter, err := NewLimiter(rdb, WithFailOpen())
+	require.NoError(t, err)
+
+	called := false
+	handler := Middleware(limiter, func(*http.Request) string { return "limit:key" }, 1, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-RateLimit-Remaining"))
+}
@@ -0,0 +1,34 @@
+package ratelimit
+
+type Option interface {
+	apply(*limiterOptions)
+}
+
+type limiterOptions struct {
+	Loader   *ScriptLoader
+	FailOpen bool
+}
+
+type optionFunc func(*limiterOptions)
+
+func (fn optionFunc) apply(opts *limiterOptions) {
+	fn(opts)
+}
+
+// WithScriptLoader configures the limiter to load its script using the passed
+// ScriptLoader when Prepare is called. Sharing a ScriptLoader between many
+// limiters means the script is only loaded once for each Redis client.
+func WithScriptLoader(loader *ScriptLoader) Option {
+	return optionFunc(func(opts *limiterOptions) {
+		opts.Loader = loader
+	})
+}
+
+// WithFailOpen configures the limiter to allow requests, rather than return an
+// error, if the Redis server cannot be reached. The Result of such a request
+// has Degraded set.
+func WithFailOpen() Option {
+	return optionFunc(func(opts *limiterOptions) {
+		opts.FailOpen = true
+	})
+}
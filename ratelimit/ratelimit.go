@@ -17,9 +17,13 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/replicate/go/logging"
 )
 
 var (
@@ -34,6 +38,7 @@ var (
 
 type Limiter struct {
 	client redis.Cmdable
+	opts   limiterOptions
 }
 
 type Result struct {
@@ -41,21 +46,68 @@ type Result struct {
 	Tokens    int           // number of tokens granted
 	Remaining int           // number of tokens remaining
 	Reset     time.Duration // time until bucket is full
+	Degraded  bool          // whether the request was allowed because the limiter failed (see WithFailOpen)
 }
 
-func NewLimiter(client redis.Cmdable) (Limiter, error) {
+func NewLimiter(client redis.Cmdable, options ...Option) (Limiter, error) {
 	if client == nil {
 		return Limiter{}, ErrNilClient
 	}
-	return Limiter{client: client}, nil
+	l := Limiter{client: client}
+	for _, o := range options {
+		o.apply(&l.opts)
+	}
+	return l, nil
 }
 
 // Prepare stores the limiter script in the Redis script cache so that it can be
 // more efficiently called with EVALSHA.
 func (l Limiter) Prepare(ctx context.Context) error {
+	if l.opts.Loader != nil {
+		return l.opts.Loader.Load(ctx, l.client)
+	}
 	return limiterScript.Load(ctx, l.client).Err()
 }
 
+// ScriptsLoaded reports whether the limiter script is present in the Redis
+// script cache, under the key "token_bucket". This can be used in health checks
+// to detect a flushed script cache (e.g. after a Redis restart).
+func (l Limiter) ScriptsLoaded(ctx context.Context) (map[string]bool, error) {
+	exists, err := l.client.ScriptExists(ctx, limiterScript.Hash()).Result()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]bool{"token_bucket": exists[0]}, nil
+}
+
+// ScriptLoader stores the limiter script in the Redis script cache at most once
+// for each client, so that services which construct many limiters can avoid
+// redundant SCRIPT LOAD calls. The zero value is ready to use. See
+// WithScriptLoader.
+type ScriptLoader struct {
+	mu     sync.Mutex
+	loaded map[redis.Cmdable]struct{}
+}
+
+// Load stores the limiter script in the Redis script cache of client, unless
+// it has already been successfully loaded by this ScriptLoader.
+func (s *ScriptLoader) Load(ctx context.Context, client redis.Cmdable) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.loaded[client]; ok {
+		return nil
+	}
+	if err := limiterScript.Load(ctx, client).Err(); err != nil {
+		return err
+	}
+	if s.loaded == nil {
+		s.loaded = make(map[redis.Cmdable]struct{})
+	}
+	s.loaded[client] = struct{}{}
+	return nil
+}
+
 // Take requests a specified number of tokens from the token bucket stored in
 // the named key, while also specifying the desired rate and capacity for the
 // bucket. It returns the Result of the request, and the first error
@@ -74,9 +126,54 @@ func (l Limiter) Take(ctx context.Context, key string, tokens, rate, capacity in
 		return nil, fmt.Errorf("%w (capacity=%d)", ErrNegativeInput, capacity)
 	}
 	cmd := limiterScript.Run(ctx, l.client, []string{key}, tokens, rate, capacity)
+	if err := cmd.Err(); err != nil && l.opts.FailOpen {
+		logger.With(logging.GetFields(ctx)...).Warn("rate limiter failed: failing open", zap.Error(err))
+		return &Result{OK: true, Tokens: tokens, Degraded: true}, nil
+	}
 	return makeResult(tokens, cmd)
 }
 
+// TakeWait behaves like Take, but if the request cannot be entirely fulfilled
+// immediately it waits until enough tokens should have accrued and tries again,
+// until all the requested tokens have been granted or ctx is done.
+//
+// Tokens granted by earlier attempts count towards the request, so on success
+// the Result has OK set and Tokens equal to tokens. If ctx is cancelled, or its
+// deadline would pass before enough tokens could accrue, TakeWait returns the
+// context's error along with a Result describing the tokens granted so far.
+// Those tokens are not returned to the bucket.
+//
+// If rate is zero the bucket never refills, so TakeWait returns without waiting,
+// as Take would.
+func (l Limiter) TakeWait(ctx context.Context, key string, tokens, rate, capacity int) (*Result, error) {
+	granted := 0
+	for {
+		r, err := l.Take(ctx, key, tokens-granted, rate, capacity)
+		if err != nil {
+			return nil, err
+		}
+		granted += r.Tokens
+		r.Tokens = granted
+		r.OK = granted == tokens
+		if r.OK || rate == 0 {
+			return r, nil
+		}
+
+		wait := time.Duration(float64(tokens-granted) / float64(rate) * float64(time.Second))
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return r, context.DeadlineExceeded
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return r, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
 // SetOptions sets the desired rate and capacity for the token bucket stored in
 // the named key. It returns the first error encountered, if any.
 //
@@ -2,11 +2,13 @@ package ratelimit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
 	"time"
 
+	"github.com/go-redis/redismock/v9"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -62,6 +64,47 @@ Outer:
 	assert.InDelta(t, expectedPermitted, permitted, float64(expectedPermitted/100))
 }
 
+func TestLimiterTakeWaitIntegration(t *testing.T) {
+	ctx := test.Context(t)
+	rdb := test.Redis(ctx, t)
+
+	limiter, _ := NewLimiter(rdb)
+	require.NoError(t, limiter.Prepare(ctx))
+
+	// Drain the bucket, then wait for more tokens than it can hold at once.
+	r, err := limiter.Take(ctx, "limit:takewait", 2, 20, 2)
+	require.NoError(t, err)
+	require.True(t, r.OK)
+
+	start := time.Now()
+	r, err = limiter.TakeWait(ctx, "limit:takewait", 4, 20, 2)
+	require.NoError(t, err)
+	assert.True(t, r.OK)
+	assert.Equal(t, 4, r.Tokens)
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		r, err := limiter.TakeWait(ctx, "limit:takewait:slow", 2, 1, 1)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.False(t, r.OK)
+		assert.Equal(t, 1, r.Tokens)
+	})
+
+	t.Run("DeadlineTooSoon", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		start := time.Now()
+		r, err := limiter.TakeWait(ctx, "limit:takewait:slower", 10, 1, 1)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.False(t, r.OK)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+}
+
 // Regression test for a bug where we weren't setting a TTL on the key the first
 // time the limiter was called.
 func TestLimiterAlwaysSetsExpiry(t *testing.T) {
@@ -117,3 +160,85 @@ func TestLimiterSetOptionsWithNegativeInputsReturnsError(t *testing.T) {
 		require.ErrorIs(t, err, ErrNegativeInput)
 	}
 }
+
+func TestLimiterSharedScriptLoaderLoadsOnce(t *testing.T) {
+	ctx := test.Context(t)
+
+	client, mock := redismock.NewClientMock()
+	mock.ExpectScriptLoad(limiterCmd).SetVal("sha")
+
+	var loader ScriptLoader
+	for range 3 {
+		limiter, err := NewLimiter(client, WithScriptLoader(&loader))
+		require.NoError(t, err)
+		require.NoError(t, limiter.Prepare(ctx))
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLimiterSharedScriptLoaderRetriesAfterError(t *testing.T) {
+	ctx := test.Context(t)
+
+	client, mock := redismock.NewClientMock()
+	mock.ExpectScriptLoad(limiterCmd).SetErr(errors.New("boom"))
+	mock.ExpectScriptLoad(limiterCmd).SetVal("sha")
+
+	var loader ScriptLoader
+	limiter, err := NewLimiter(client, WithScriptLoader(&loader))
+	require.NoError(t, err)
+
+	require.Error(t, limiter.Prepare(ctx))
+	require.NoError(t, limiter.Prepare(ctx))
+	require.NoError(t, limiter.Prepare(ctx))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLimiterFailOpen(t *testing.T) {
+	ctx := test.Context(t)
+
+	client, mock := redismock.NewClientMock()
+	mock.Regexp().ExpectEvalSha(`.*`, []string{"testkey"}, 1, 10, 100).SetErr(errors.New("connection refused"))
+
+	limiter, err := NewLimiter(client, WithFailOpen())
+	require.NoError(t, err)
+
+	r, err := limiter.Take(ctx, "testkey", 1, 10, 100)
+	require.NoError(t, err)
+	assert.True(t, r.OK)
+	assert.True(t, r.Degraded)
+	assert.Equal(t, 1, r.Tokens)
+}
+
+func TestLimiterFailsClosedByDefault(t *testing.T) {
+	ctx := test.Context(t)
+
+	client, mock := redismock.NewClientMock()
+	mock.Regexp().ExpectEvalSha(`.*`, []string{"testkey"}, 1, 10, 100).SetErr(errors.New("connection refused"))
+
+	limiter, err := NewLimiter(client)
+	require.NoError(t, err)
+
+	r, err := limiter.Take(ctx, "testkey", 1, 10, 100)
+	require.Error(t, err)
+	assert.Nil(t, r)
+}
+
+func TestLimiterScriptsLoaded(t *testing.T) {
+	ctx := test.Context(t)
+	_, rdb := test.MiniRedis(t)
+
+	limiter, err := NewLimiter(rdb)
+	require.NoError(t, err)
+	require.NoError(t, limiter.Prepare(ctx))
+
+	loaded, err := limiter.ScriptsLoaded(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"token_bucket": true}, loaded)
+
+	require.NoError(t, rdb.ScriptFlush(ctx).Err())
+	loaded, err = limiter.ScriptsLoaded(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"token_bucket": false}, loaded)
+}
@@ -8,11 +8,13 @@ import (
 	"sort"
 
 	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 )
 
 var (
-	ErrUnsupportedValue      = fmt.Errorf("unsupported value")
-	ErrUnsupportedSliceValue = fmt.Errorf("%w: slice attributes may contain only one type", ErrUnsupportedValue)
+	ErrUnsupportedValue       = fmt.Errorf("unsupported value")
+	ErrUnsupportedSliceValue  = fmt.Errorf("%w: slice attributes may contain only one type", ErrUnsupportedValue)
+	ErrUnsupportedNestedValue = fmt.Errorf("%w: maps may only be nested one level deep", ErrUnsupportedValue)
 )
 
 // Attributes is a wrapper around a slice of attribute.KeyValue values which
@@ -21,10 +23,75 @@ var (
 // attributes (int64 if possible, float64 otherwise).
 type Attributes []attribute.KeyValue
 
+// AttributesOption customizes the behavior of UnmarshalAttributes.
+type AttributesOption interface {
+	apply(*attributesOptions)
+}
+
+type attributesOptions struct {
+	FlattenMaps  bool
+	FloatNumbers bool
+}
+
+type attributesOptionFunc func(*attributesOptions)
+
+func (fn attributesOptionFunc) apply(opts *attributesOptions) {
+	fn(opts)
+}
+
+// WithFlattenedMaps configures unmarshaling to flatten single-level nested maps
+// into dotted attribute keys, so that {"a": {"b": 1}} becomes an attribute
+// a.b=1. Maps nested more deeply than this are skipped.
+func WithFlattenedMaps() AttributesOption {
+	return attributesOptionFunc(func(opts *attributesOptions) {
+		opts.FlattenMaps = true
+	})
+}
+
+// WithFloatNumbers configures unmarshaling to convert all JSON numbers to
+// float64 attributes, including those which could be represented as int64. This
+// ensures that an attribute has the same type regardless of whether a
+// particular value happens to be integral (e.g. 1 vs 1.5).
+func WithFloatNumbers() AttributesOption {
+	return attributesOptionFunc(func(opts *attributesOptions) {
+		opts.FloatNumbers = true
+	})
+}
+
 func (as Attributes) AsSlice() []attribute.KeyValue {
 	return []attribute.KeyValue(as)
 }
 
+// ZapFields converts the attributes into a slice of typed zap.Field values, so
+// that the same attributes can be attached to both spans and log messages.
+func (as Attributes) ZapFields() []zap.Field {
+	fields := make([]zap.Field, 0, len(as))
+	for _, a := range as {
+		key := string(a.Key)
+		switch a.Value.Type() {
+		case attribute.BOOL:
+			fields = append(fields, zap.Bool(key, a.Value.AsBool()))
+		case attribute.INT64:
+			fields = append(fields, zap.Int64(key, a.Value.AsInt64()))
+		case attribute.FLOAT64:
+			fields = append(fields, zap.Float64(key, a.Value.AsFloat64()))
+		case attribute.STRING:
+			fields = append(fields, zap.String(key, a.Value.AsString()))
+		case attribute.BOOLSLICE:
+			fields = append(fields, zap.Bools(key, a.Value.AsBoolSlice()))
+		case attribute.INT64SLICE:
+			fields = append(fields, zap.Int64s(key, a.Value.AsInt64Slice()))
+		case attribute.FLOAT64SLICE:
+			fields = append(fields, zap.Float64s(key, a.Value.AsFloat64Slice()))
+		case attribute.STRINGSLICE:
+			fields = append(fields, zap.Strings(key, a.Value.AsStringSlice()))
+		default:
+			fields = append(fields, zap.Any(key, a.Value.AsInterface()))
+		}
+	}
+	return fields
+}
+
 func (as Attributes) MarshalJSON() ([]byte, error) {
 	attrMap := make(map[string]any)
 	for _, a := range as {
@@ -34,6 +101,26 @@ func (as Attributes) MarshalJSON() ([]byte, error) {
 }
 
 func (as *Attributes) UnmarshalJSON(b []byte) error {
+	return as.unmarshal(b, attributesOptions{})
+}
+
+// UnmarshalAttributes parses a JSON dictionary into Attributes, in the same
+// manner as json.Unmarshal, but allows the caller to customize how values are
+// interpreted by passing one or more AttributesOption values.
+func UnmarshalAttributes(b []byte, options ...AttributesOption) (Attributes, error) {
+	var opts attributesOptions
+	for _, o := range options {
+		o.apply(&opts)
+	}
+
+	var as Attributes
+	if err := as.unmarshal(b, opts); err != nil {
+		return nil, err
+	}
+	return as, nil
+}
+
+func (as *Attributes) unmarshal(b []byte, opts attributesOptions) error {
 	var attrMap map[string]any
 
 	d := json.NewDecoder(bytes.NewReader(b))
@@ -46,15 +133,15 @@ func (as *Attributes) UnmarshalJSON(b []byte) error {
 	kvs := make([]attribute.KeyValue, 0, len(attrMap))
 
 	for k, v := range attrMap {
-		key := attribute.Key(k)
-		value, err := getValue(v)
-		if errors.Is(err, ErrUnsupportedValue) {
-			logger.Sugar().Warnw("skipping unsupported attribute value", "key", k, "error", err)
-			continue
-		} else if err != nil {
+		var err error
+		if m, ok := v.(map[string]any); ok && opts.FlattenMaps {
+			kvs, err = appendFlattened(kvs, k, m, opts)
+		} else {
+			kvs, err = appendValue(kvs, k, v, opts)
+		}
+		if err != nil {
 			return err
 		}
-		kvs = append(kvs, attribute.KeyValue{Key: key, Value: value})
 	}
 	sort.Slice(kvs, func(i, j int) bool {
 		return string(kvs[i].Key) < string(kvs[j].Key)
@@ -65,10 +152,44 @@ func (as *Attributes) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
-func getValue(value any) (attribute.Value, error) {
+// appendValue converts v to an attribute value and appends it to kvs under the
+// given key. Unsupported values are logged and skipped.
+func appendValue(kvs []attribute.KeyValue, k string, v any, opts attributesOptions) ([]attribute.KeyValue, error) {
+	value, err := getValue(v, opts)
+	if errors.Is(err, ErrUnsupportedValue) {
+		logger.Sugar().Warnw("skipping unsupported attribute value", "key", k, "error", err)
+		return kvs, nil
+	} else if err != nil {
+		return kvs, err
+	}
+	return append(kvs, attribute.KeyValue{Key: attribute.Key(k), Value: value}), nil
+}
+
+// appendFlattened appends the entries of a single-level nested map to kvs
+// using dotted keys, so that {"a": {"b": 1}} becomes a.b=1. Maps which
+// themselves contain maps are skipped entirely.
+func appendFlattened(kvs []attribute.KeyValue, k string, m map[string]any, opts attributesOptions) ([]attribute.KeyValue, error) {
+	for _, v := range m {
+		if _, ok := v.(map[string]any); ok {
+			logger.Sugar().Warnw("skipping unsupported attribute value", "key", k, "error", ErrUnsupportedNestedValue)
+			return kvs, nil
+		}
+	}
+
+	var err error
+	for nk, v := range m {
+		kvs, err = appendValue(kvs, k+"."+nk, v, opts)
+		if err != nil {
+			return kvs, err
+		}
+	}
+	return kvs, nil
+}
+
+func getValue(value any, opts attributesOptions) (attribute.Value, error) {
 	switch v := value.(type) {
 	case json.Number:
-		if asInt64, err := v.Int64(); err == nil {
+		if asInt64, err := v.Int64(); err == nil && !opts.FloatNumbers {
 			return attribute.Int64Value(asInt64), nil
 		}
 		if asFloat64, err := v.Float64(); err == nil {
@@ -81,19 +202,19 @@ func getValue(value any) (attribute.Value, error) {
 	case string:
 		return attribute.StringValue(v), nil
 	case []any:
-		return getSliceValue(v)
+		return getSliceValue(v, opts)
 	default:
 		return attribute.Value{}, ErrUnsupportedValue
 	}
 }
 
-func getSliceValue(values []any) (attribute.Value, error) {
+func getSliceValue(values []any, opts attributesOptions) (attribute.Value, error) {
 	if len(values) == 0 {
 		// We have no type information, we arbitrarily decide it's a string slice.
 		return attribute.StringSliceValue([]string{}), nil
 	}
 
-	var isFloat bool
+	isFloat := opts.FloatNumbers
 
 	if _, ok := values[0].(json.Number); ok {
 		// If it's a json.Number, then we only map to int64 if *all* the values can
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 )
 
 var attributeTestCases = []struct {
@@ -167,3 +168,114 @@ func TestAttributesUnmarshalInvalidTypes(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalAttributesFlattenedMaps(t *testing.T) {
+	attrs, err := UnmarshalAttributes(
+		[]byte(`{"name": "Boz", "flag": {"enabled": true, "ratio": 0.5, "tags": ["a", "b"]}}`),
+		WithFlattenedMaps(),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, Attributes{
+		attribute.Bool("flag.enabled", true),
+		attribute.Float64("flag.ratio", 0.5),
+		attribute.StringSlice("flag.tags", []string{"a", "b"}),
+		attribute.String("name", "Boz"),
+	}, attrs)
+}
+
+func TestUnmarshalAttributesFlattenedMapsSkipsDeeperNesting(t *testing.T) {
+	testCases := []struct {
+		Name string
+		JSON string
+	}{
+		{
+			Name: "empty map",
+			JSON: `{"map": {}}`,
+		},
+		{
+			Name: "nested map",
+			JSON: `{"map": {"inner": {"name": "Chigozie"}}}`,
+		},
+		{
+			Name: "mixed map",
+			JSON: `{"map": {"age": 42, "inner": {"name": "Chigozie"}}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			attrs, err := UnmarshalAttributes([]byte(tc.JSON), WithFlattenedMaps())
+			require.NoError(t, err)
+
+			assert.Empty(t, attrs)
+		})
+	}
+}
+
+func TestUnmarshalAttributesFloatNumbers(t *testing.T) {
+	attrs, err := UnmarshalAttributes(
+		[]byte(`{"count": 1, "ratio": 1.5, "ints": [1, 2, 3], "mixed": [1, 2.5], "name": "Boz", "flag": {"limit": 10}}`),
+		WithFloatNumbers(),
+		WithFlattenedMaps(),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, Attributes{
+		attribute.Float64("count", 1),
+		attribute.Float64("flag.limit", 10),
+		attribute.Float64Slice("ints", []float64{1, 2, 3}),
+		attribute.Float64Slice("mixed", []float64{1, 2.5}),
+		attribute.String("name", "Boz"),
+		attribute.Float64("ratio", 1.5),
+	}, attrs)
+}
+
+func TestUnmarshalAttributesFloatNumbersConsistentTypes(t *testing.T) {
+	a, err := UnmarshalAttributes([]byte(`{"value": 1}`), WithFloatNumbers())
+	require.NoError(t, err)
+	b, err := UnmarshalAttributes([]byte(`{"value": 1.0}`), WithFloatNumbers())
+	require.NoError(t, err)
+
+	assert.Equal(t, attribute.FLOAT64, a[0].Value.Type())
+	assert.Equal(t, a, b)
+}
+
+func TestUnmarshalAttributesWithoutOptionsMatchesUnmarshalJSON(t *testing.T) {
+	for _, tc := range attributeTestCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			attrs, err := UnmarshalAttributes([]byte(tc.JSON))
+			require.NoError(t, err)
+
+			assert.Equal(t, Attributes(tc.KVs), attrs)
+		})
+	}
+}
+
+func TestAttributesZapFields(t *testing.T) {
+	attrs := Attributes{
+		attribute.Bool("enabled", true),
+		attribute.Int("age", 42),
+		attribute.Float64("pi", 3.141592653589793),
+		attribute.String("name", "Florp"),
+		attribute.BoolSlice("flags", []bool{true, false}),
+		attribute.IntSlice("lotto", []int{12, 17, 46}),
+		attribute.Float64Slice("coordinates", []float64{51.477928, -0.001545}),
+		attribute.StringSlice("hobbies", []string{"gardening", "fishing"}),
+	}
+
+	assert.Equal(t, []zap.Field{
+		zap.Bool("enabled", true),
+		zap.Int64("age", 42),
+		zap.Float64("pi", 3.141592653589793),
+		zap.String("name", "Florp"),
+		zap.Bools("flags", []bool{true, false}),
+		zap.Int64s("lotto", []int64{12, 17, 46}),
+		zap.Float64s("coordinates", []float64{51.477928, -0.001545}),
+		zap.Strings("hobbies", []string{"gardening", "fishing"}),
+	}, attrs.ZapFields())
+}
+
+func TestAttributesZapFieldsEmpty(t *testing.T) {
+	assert.Empty(t, Attributes{}.ZapFields())
+}
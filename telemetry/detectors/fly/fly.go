@@ -11,10 +11,38 @@ import (
 
 var _ resource.Detector = (*detector)(nil)
 
-type detector struct{}
+// Option customizes the behavior of the detector returned by NewDetector.
+type Option interface {
+	apply(*detector)
+}
+
+type optionFunc func(*detector)
+
+func (fn optionFunc) apply(d *detector) {
+	fn(d)
+}
 
-func NewDetector() resource.Detector {
-	return &detector{}
+// WithPrefix configures the detector to namespace the Fly-specific attributes
+// it emits under prefix, so that with a prefix of "r8" the app name is reported
+// as r8.fly.app_name. Standard semantic convention attributes (cloud.provider,
+// service.instance.id, etc.) are not affected. An empty prefix leaves all keys
+// unchanged.
+func WithPrefix(prefix string) Option {
+	return optionFunc(func(d *detector) {
+		d.prefix = prefix
+	})
+}
+
+type detector struct {
+	prefix string
+}
+
+func NewDetector(options ...Option) resource.Detector {
+	d := &detector{}
+	for _, o := range options {
+		o.apply(d)
+	}
+	return d
 }
 
 func (d *detector) Detect(_ context.Context) (*resource.Resource, error) {
@@ -26,24 +54,37 @@ func (d *detector) Detect(_ context.Context) (*resource.Resource, error) {
 		semconv.CloudProviderKey.String("fly"),
 	}
 
-	attrs = addEnvAttr(attrs, "FLY_APP_NAME", attribute.Key("fly.app_name"))
-	attrs = addEnvAttr(attrs, "FLY_IMAGE_REF", attribute.Key("fly.image_ref"))
+	attrs = addEnvAttr(attrs, "FLY_APP_NAME", d.key("app_name"))
+	attrs = addEnvAttr(attrs, "FLY_APP_VERSION",
+		attribute.Key("deployment.version"),
+		d.key("app_version"),
+	)
+	attrs = addEnvAttr(attrs, "FLY_IMAGE_REF", d.key("image_ref"))
 	attrs = addEnvAttr(attrs, "FLY_MACHINE_ID",
 		semconv.ServiceInstanceIDKey,
-		attribute.Key("fly.machine_id"),
+		d.key("machine_id"),
 	)
-	attrs = addEnvAttr(attrs, "FLY_MACHINE_VERSION", attribute.Key("fly.machine_version"))
-	attrs = addEnvAttr(attrs, "FLY_PUBLIC_IP", attribute.Key("fly.public_ip"))
-	attrs = addEnvAttr(attrs, "FLY_PRIVATE_IP", attribute.Key("fly.private_ip"))
-	attrs = addEnvAttr(attrs, "FLY_PROCESS_GROUP", attribute.Key("fly.process_group"))
+	attrs = addEnvAttr(attrs, "FLY_MACHINE_VERSION", d.key("machine_version"))
+	attrs = addEnvAttr(attrs, "FLY_PUBLIC_IP", d.key("public_ip"))
+	attrs = addEnvAttr(attrs, "FLY_PRIVATE_IP", d.key("private_ip"))
+	attrs = addEnvAttr(attrs, "FLY_PROCESS_GROUP", d.key("process_group"))
 	attrs = addEnvAttr(attrs, "FLY_REGION",
 		semconv.CloudRegionKey,
-		attribute.Key("fly.region"),
+		d.key("region"),
 	)
 
 	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
 }
 
+// key returns the attribute key for a Fly-specific attribute, applying the
+// configured prefix if any.
+func (d *detector) key(name string) attribute.Key {
+	if d.prefix == "" {
+		return attribute.Key("fly." + name)
+	}
+	return attribute.Key(d.prefix + ".fly." + name)
+}
+
 func isFly() bool {
 	_, ok := os.LookupEnv("FLY_APP_NAME")
 	return ok
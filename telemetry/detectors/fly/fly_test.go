@@ -0,0 +1,71 @@
+package fly
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestDetectNotFly(t *testing.T) {
+	res, err := NewDetector().Detect(context.Background())
+	require.NoError(t, err)
+
+	assert.Empty(t, res.Attributes())
+}
+
+func TestDetectAppVersion(t *testing.T) {
+	t.Setenv("FLY_APP_NAME", "giraffe")
+	t.Setenv("FLY_APP_VERSION", "42")
+	t.Setenv("FLY_MACHINE_VERSION", "01HXYZ")
+
+	res, err := NewDetector().Detect(context.Background())
+	require.NoError(t, err)
+
+	set := res.Set()
+
+	v, ok := set.Value(attribute.Key("deployment.version"))
+	require.True(t, ok)
+	assert.Equal(t, "42", v.AsString())
+
+	v, ok = set.Value(attribute.Key("fly.app_version"))
+	require.True(t, ok)
+	assert.Equal(t, "42", v.AsString())
+
+	v, ok = set.Value(attribute.Key("fly.machine_version"))
+	require.True(t, ok)
+	assert.Equal(t, "01HXYZ", v.AsString())
+}
+
+func TestDetectAppVersionAbsent(t *testing.T) {
+	t.Setenv("FLY_APP_NAME", "giraffe")
+
+	res, err := NewDetector().Detect(context.Background())
+	require.NoError(t, err)
+
+	_, ok := res.Set().Value(attribute.Key("deployment.version"))
+	assert.False(t, ok)
+}
+
+func TestDetectWithPrefix(t *testing.T) {
+	t.Setenv("FLY_APP_NAME", "giraffe")
+	t.Setenv("FLY_APP_VERSION", "42")
+	t.Setenv("FLY_MACHINE_ID", "abc123")
+	t.Setenv("FLY_REGION", "ord")
+
+	res, err := NewDetector(WithPrefix("r8")).Detect(context.Background())
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("cloud.provider", "fly"),
+		attribute.String("cloud.region", "ord"),
+		attribute.String("deployment.version", "42"),
+		attribute.String("service.instance.id", "abc123"),
+		attribute.String("r8.fly.app_name", "giraffe"),
+		attribute.String("r8.fly.app_version", "42"),
+		attribute.String("r8.fly.machine_id", "abc123"),
+		attribute.String("r8.fly.region", "ord"),
+	}, res.Attributes())
+}
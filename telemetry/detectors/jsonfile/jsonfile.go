@@ -0,0 +1,53 @@
+// Package jsonfile provides a resource detector which reads resource attributes
+// from a JSON file, for environments where attributes are provided via a
+// mounted file rather than the OTEL_RESOURCE_ATTRIBUTES environment variable.
+package jsonfile
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// PathEnv is the environment variable which holds the path to the JSON file.
+const PathEnv = "OTEL_RESOURCE_ATTRIBUTES_FILE"
+
+var _ resource.Detector = (*detector)(nil)
+
+// DecodeFunc decodes the contents of a JSON file into attributes.
+type DecodeFunc func([]byte) ([]attribute.KeyValue, error)
+
+type detector struct {
+	decode DecodeFunc
+}
+
+// NewDetector returns a detector which reads a JSON object from the file named
+// by the OTEL_RESOURCE_ATTRIBUTES_FILE environment variable, and converts it to
+// resource attributes using the passed decode function. If the environment
+// variable is not set, the detector returns an empty resource.
+func NewDetector(decode DecodeFunc) resource.Detector {
+	return &detector{decode: decode}
+}
+
+func (d *detector) Detect(_ context.Context) (*resource.Resource, error) {
+	path, ok := os.LookupEnv(PathEnv)
+	if !ok || path == "" {
+		return resource.Empty(), nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource attributes file: %w", err)
+	}
+
+	attrs, err := d.decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode resource attributes file %s: %w", path, err)
+	}
+
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
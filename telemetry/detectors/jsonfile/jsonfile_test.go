@@ -0,0 +1,71 @@
+package jsonfile
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func decodeStrings(b []byte) ([]attribute.KeyValue, error) {
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	var attrs []attribute.KeyValue
+	for k, v := range m {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	return attrs, nil
+}
+
+func writeFile(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "resource.json")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv(PathEnv, writeFile(t, `{"service.namespace": "zoo", "k8s.cluster.name": "savannah"}`))
+
+	res, err := NewDetector(decodeStrings).Detect(context.Background())
+	require.NoError(t, err)
+
+	v, ok := res.Set().Value("service.namespace")
+	require.True(t, ok)
+	assert.Equal(t, "zoo", v.AsString())
+
+	v, ok = res.Set().Value("k8s.cluster.name")
+	require.True(t, ok)
+	assert.Equal(t, "savannah", v.AsString())
+}
+
+func TestDetectUnset(t *testing.T) {
+	t.Setenv(PathEnv, "")
+
+	res, err := NewDetector(decodeStrings).Detect(context.Background())
+	require.NoError(t, err)
+
+	assert.Empty(t, res.Attributes())
+}
+
+func TestDetectMissingFile(t *testing.T) {
+	t.Setenv(PathEnv, filepath.Join(t.TempDir(), "nope.json"))
+
+	_, err := NewDetector(decodeStrings).Detect(context.Background())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDetectInvalidJSON(t *testing.T) {
+	t.Setenv(PathEnv, writeFile(t, `{"service.namespace": `))
+
+	_, err := NewDetector(decodeStrings).Detect(context.Background())
+	assert.Error(t, err)
+}
@@ -7,13 +7,16 @@ import (
 	"os"
 	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	"go.uber.org/zap"
 
+	promclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/replicate/go/version"
@@ -52,9 +55,7 @@ func configureMeterProvider(enableOTLP bool) {
 }
 
 func createMeterProvider(ctx context.Context, enableOTLP bool) (metric.MeterProvider, error) {
-	opts := []sdkmetric.Option{
-		sdkmetric.WithResource(DefaultResource()),
-	}
+	opts := meterProviderOptions()
 
 	// Always export metrics to Prometheus.
 	prom, err := prometheus.New()
@@ -77,9 +78,54 @@ func createMeterProvider(ctx context.Context, enableOTLP bool) (metric.MeterProv
 	return mp, nil
 }
 
+// metricsHandler serves metrics from the default Prometheus registry. The
+// OpenMetrics format is enabled as it is the only format which can carry
+// exemplars.
+func metricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(
+		promclient.DefaultRegisterer,
+		promhttp.HandlerFor(promclient.DefaultGatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+		}),
+	)
+}
+
+// StartRuntimeMetrics starts collecting Go runtime metrics (memory usage,
+// garbage collection, goroutine counts, etc.) using the configured meter
+// provider, so that they are exported alongside all other metrics. If the meter
+// provider could not be configured, this does nothing.
+func StartRuntimeMetrics() error {
+	mp, ok := otel.GetMeterProvider().(*sdkmetric.MeterProvider)
+	if !ok {
+		return nil
+	}
+	return startRuntimeMetrics(mp)
+}
+
+func startRuntimeMetrics(mp metric.MeterProvider) error {
+	return runtime.Start(runtime.WithMeterProvider(mp))
+}
+
+// meterProviderOptions returns the options common to all meter providers,
+// independent of where metrics are exported.
+func meterProviderOptions() []sdkmetric.Option {
+	opts := []sdkmetric.Option{
+		sdkmetric.WithResource(DefaultResource()),
+	}
+
+	// Attach exemplars carrying the trace and span IDs to measurements recorded
+	// within sampled spans, unless the filter has been explicitly configured
+	// through the environment.
+	if _, ok := os.LookupEnv("OTEL_METRICS_EXEMPLAR_FILTER"); !ok {
+		opts = append(opts, sdkmetric.WithExemplarFilter(exemplar.TraceBasedFilter))
+	}
+
+	return opts
+}
+
 func serveMetrics() {
 	mux := http.ServeMux{}
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/metrics", metricsHandler())
 
 	s := &http.Server{
 		Addr:    Addr,
@@ -0,0 +1,96 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMeterProviderRecordsExemplars(t *testing.T) {
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(append(meterProviderOptions(), sdkmetric.WithReader(reader))...)
+	t.Cleanup(func() { _ = mp.Shutdown(ctx) })
+
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	require.NoError(t, err)
+
+	traceID := trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	spanID := trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	spanCtx := trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	counter.Add(spanCtx, 1)
+	counter.Add(ctx, 1) // not in a span, so should not produce an exemplar
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+
+	sum, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, sum.DataPoints, 1)
+
+	exemplars := sum.DataPoints[0].Exemplars
+	require.Len(t, exemplars, 1)
+	assert.Equal(t, traceID[:], exemplars[0].TraceID)
+	assert.Equal(t, spanID[:], exemplars[0].SpanID)
+}
+
+func TestMeterProviderExemplarFilterFromEnv(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("OTEL_METRICS_EXEMPLAR_FILTER", "always_off")
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(append(meterProviderOptions(), sdkmetric.WithReader(reader))...)
+	t.Cleanup(func() { _ = mp.Shutdown(ctx) })
+
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	require.NoError(t, err)
+
+	spanCtx := trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x01},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	counter.Add(spanCtx, 1)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	sum := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	assert.Empty(t, sum.DataPoints[0].Exemplars)
+}
+
+func TestStartRuntimeMetrics(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("OTEL_GO_X_DEPRECATED_RUNTIME_METRICS", "false")
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(append(meterProviderOptions(), sdkmetric.WithReader(reader))...)
+	t.Cleanup(func() { _ = mp.Shutdown(ctx) })
+
+	require.NoError(t, startRuntimeMetrics(mp))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+
+	var names []string
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names = append(names, m.Name)
+		}
+	}
+
+	assert.Contains(t, names, "go.goroutine.count")
+	assert.Contains(t, names, "go.memory.used")
+}
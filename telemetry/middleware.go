@@ -0,0 +1,33 @@
+package telemetry
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// AttributesMiddleware returns HTTP middleware which reads a JSON dictionary of
+// span attributes from the named request header and sets them on the span
+// found on the request context. This allows upstream services to annotate the
+// traces of the requests they make.
+//
+// The header is parsed as for UnmarshalAttributes with the passed options, so
+// unsupported values are skipped. If the header is missing the request is
+// passed through untouched, and if it is malformed a warning is logged and the
+// request is passed through without any attributes being set.
+func AttributesMiddleware(header string, options ...AttributesOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if v := r.Header.Get(header); v != "" {
+				attrs, err := UnmarshalAttributes([]byte(v), options...)
+				if err != nil {
+					logger.Warn("ignoring malformed attributes header", zap.String("header", header), zap.Error(err))
+				} else {
+					trace.SpanFromContext(r.Context()).SetAttributes(attrs.AsSlice()...)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
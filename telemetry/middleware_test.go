@@ -0,0 +1,71 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAttributesMiddleware(t *testing.T) {
+	testcases := []struct {
+		name   string
+		header string
+		want   []attribute.KeyValue
+	}{
+		{
+			name:   "Valid",
+			header: `{"animal": "giraffe", "legs": 4, "tall": true}`,
+			want: []attribute.KeyValue{
+				attribute.String("animal", "giraffe"),
+				attribute.Int64("legs", 4),
+				attribute.Bool("tall", true),
+			},
+		},
+		{
+			name:   "UnsupportedValuesSkipped",
+			header: `{"animal": "giraffe", "mixed": [1, "two"]}`,
+			want: []attribute.KeyValue{
+				attribute.String("animal", "giraffe"),
+			},
+		},
+		{
+			name:   "Malformed",
+			header: `{"animal": `,
+		},
+		{
+			name: "Missing",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+			called := false
+			handler := AttributesMiddleware("X-Trace-Attributes")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+
+			ctx, span := tracer.Start(context.Background(), "request")
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			if tc.header != "" {
+				req.Header.Set("X-Trace-Attributes", tc.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			span.End()
+
+			assert.True(t, called)
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.ElementsMatch(t, tc.want, spans[0].Attributes())
+		})
+	}
+}
@@ -2,7 +2,9 @@ package telemetry
 
 import (
 	"context"
+	"unicode/utf8"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
 
@@ -83,3 +85,140 @@ func (p *TraceOptionsProcessor) Shutdown(ctx context.Context) error {
 func (p *TraceOptionsProcessor) ForceFlush(ctx context.Context) error {
 	return p.Next.ForceFlush(ctx)
 }
+
+// RedactedValue is the value which replaces redacted attribute values.
+const RedactedValue = "[redacted]"
+
+// Check RedactingProcessor implements SpanProcessor
+var _ trace.SpanProcessor = new(RedactingProcessor)
+
+// RedactingProcessor replaces the values of sensitive span attributes with
+// RedactedValue before the span is passed on for export.
+type RedactingProcessor struct {
+	Next trace.SpanProcessor
+
+	// Redact reports whether the value of the passed attribute should be
+	// redacted. See RedactKeys for a simple implementation.
+	Redact func(attribute.KeyValue) bool
+}
+
+// RedactKeys returns a function suitable for use as RedactingProcessor.Redact
+// which redacts attributes with any of the passed keys.
+func RedactKeys(keys ...string) func(attribute.KeyValue) bool {
+	set := make(map[attribute.Key]struct{}, len(keys))
+	for _, k := range keys {
+		set[attribute.Key(k)] = struct{}{}
+	}
+	return func(kv attribute.KeyValue) bool {
+		_, ok := set[kv.Key]
+		return ok
+	}
+}
+
+func (p *RedactingProcessor) OnStart(parent context.Context, s trace.ReadWriteSpan) {
+	p.Next.OnStart(parent, s)
+}
+
+func (p *RedactingProcessor) OnEnd(s trace.ReadOnlySpan) {
+	attrs := s.Attributes()
+
+	var redacted []attribute.KeyValue
+	for i, kv := range attrs {
+		if !p.Redact(kv) {
+			continue
+		}
+		if redacted == nil {
+			// Copy on first write, as the span's attributes must not be modified.
+			redacted = make([]attribute.KeyValue, len(attrs))
+			copy(redacted, attrs)
+		}
+		redacted[i] = attribute.String(string(kv.Key), RedactedValue)
+	}
+
+	if redacted != nil {
+		s = attributeOverrideSpan{ReadOnlySpan: s, attrs: redacted}
+	}
+	p.Next.OnEnd(s)
+}
+
+func (p *RedactingProcessor) Shutdown(ctx context.Context) error {
+	return p.Next.Shutdown(ctx)
+}
+
+func (p *RedactingProcessor) ForceFlush(ctx context.Context) error {
+	return p.Next.ForceFlush(ctx)
+}
+
+// DefaultMaxAttributeLength is the default maximum length, in bytes, of string
+// attribute values before they are truncated by TruncatingProcessor.
+const DefaultMaxAttributeLength = 8192
+
+// Check TruncatingProcessor implements SpanProcessor
+var _ trace.SpanProcessor = new(TruncatingProcessor)
+
+// TruncatingProcessor truncates string attribute values longer than MaxLength
+// bytes, appending an ellipsis to the truncated value. The original length of
+// each truncated value is recorded in an additional attribute with the suffix
+// ".original_length".
+type TruncatingProcessor struct {
+	Next      trace.SpanProcessor
+	MaxLength int
+}
+
+func (p *TruncatingProcessor) OnStart(parent context.Context, s trace.ReadWriteSpan) {
+	p.Next.OnStart(parent, s)
+}
+
+func (p *TruncatingProcessor) OnEnd(s trace.ReadOnlySpan) {
+	attrs := s.Attributes()
+
+	var truncated []attribute.KeyValue
+	for i, kv := range attrs {
+		if kv.Value.Type() != attribute.STRING || len(kv.Value.AsString()) <= p.MaxLength {
+			continue
+		}
+		if truncated == nil {
+			// Copy on first write, as the span's attributes must not be modified.
+			truncated = make([]attribute.KeyValue, len(attrs))
+			copy(truncated, attrs)
+		}
+		v := kv.Value.AsString()
+		truncated[i] = attribute.String(string(kv.Key), truncateString(v, p.MaxLength)+"…")
+		truncated = append(truncated, attribute.Int(string(kv.Key)+".original_length", len(v)))
+	}
+
+	if truncated != nil {
+		s = attributeOverrideSpan{ReadOnlySpan: s, attrs: truncated}
+	}
+	p.Next.OnEnd(s)
+}
+
+func (p *TruncatingProcessor) Shutdown(ctx context.Context) error {
+	return p.Next.Shutdown(ctx)
+}
+
+func (p *TruncatingProcessor) ForceFlush(ctx context.Context) error {
+	return p.Next.ForceFlush(ctx)
+}
+
+// truncateString returns the longest prefix of s no longer than n bytes which
+// does not split a UTF-8 encoded rune.
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// attributeOverrideSpan wraps a ReadOnlySpan, replacing its attributes.
+type attributeOverrideSpan struct {
+	trace.ReadOnlySpan
+	attrs []attribute.KeyValue
+}
+
+func (s attributeOverrideSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
@@ -0,0 +1,131 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRedactingProcessor(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(&RedactingProcessor{
+		Next:   recorder,
+		Redact: RedactKeys("auth.token", "user.email"),
+	}))
+
+	_, span := tp.Tracer("test").Start(ctx, "my-span")
+	span.SetAttributes(
+		attribute.String("auth.token", "r8_secret"),
+		attribute.String("user.email", "someone@example.com"),
+		attribute.String("http.method", "GET"),
+		attribute.Int("http.status_code", 200),
+	)
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("auth.token", RedactedValue),
+		attribute.String("user.email", RedactedValue),
+		attribute.String("http.method", "GET"),
+		attribute.Int("http.status_code", 200),
+	}, spans[0].Attributes())
+}
+
+func TestRedactingProcessorNoMatches(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(&RedactingProcessor{
+		Next:   recorder,
+		Redact: RedactKeys("auth.token"),
+	}))
+
+	_, span := tp.Tracer("test").Start(ctx, "my-span")
+	span.SetAttributes(attribute.String("http.method", "GET"))
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("http.method", "GET"),
+	}, spans[0].Attributes())
+}
+
+func TestSpanProcessorChainRedactsConfiguredAttributes(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+
+	var opts tracerProviderOptions
+	withRedactedAttributes("auth.token", " ", "user.email ")(&opts)
+	assert.Equal(t, []string{"auth.token", "user.email"}, opts.RedactedAttributes)
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newSpanProcessor(recorder, opts)))
+
+	_, span := tp.Tracer("test").Start(ctx, "my-span")
+	span.SetAttributes(
+		attribute.String("auth.token", "r8_secret"),
+		attribute.String("http.method", "GET"),
+	)
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("auth.token", RedactedValue),
+		attribute.String("http.method", "GET"),
+	}, spans[0].Attributes())
+}
+
+func TestTruncatingProcessor(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(&TruncatingProcessor{
+		Next:      recorder,
+		MaxLength: 10,
+	}))
+
+	_, span := tp.Tracer("test").Start(ctx, "my-span")
+	span.SetAttributes(
+		attribute.String("http.request.body", "abcdefghijklmnopqrstuvwxyz"),
+		attribute.String("http.method", "GET"),
+		attribute.String("exactly.ten", "0123456789"),
+		attribute.Int("http.status_code", 200),
+	)
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("http.request.body", "abcdefghij…"),
+		attribute.Int("http.request.body.original_length", 26),
+		attribute.String("http.method", "GET"),
+		attribute.String("exactly.ten", "0123456789"),
+		attribute.Int("http.status_code", 200),
+	}, spans[0].Attributes())
+}
+
+func TestTruncatingProcessorRespectsRuneBoundaries(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(&TruncatingProcessor{
+		Next:      recorder,
+		MaxLength: 4,
+	}))
+
+	_, span := tp.Tracer("test").Start(ctx, "my-span")
+	span.SetAttributes(attribute.String("animal", "ab🦒🦒")) // the giraffe is four bytes long
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("animal", "ab…"),
+		attribute.Int("animal.original_length", 10),
+	}, spans[0].Attributes())
+}
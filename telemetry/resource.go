@@ -2,25 +2,80 @@ package telemetry
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"sync"
 
 	"go.opentelemetry.io/contrib/detectors/gcp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 
 	"github.com/replicate/go/telemetry/detectors/fly"
+	"github.com/replicate/go/telemetry/detectors/jsonfile"
 	"github.com/replicate/go/version"
 )
 
 var (
 	defaultResource     *resource.Resource
 	defaultResourceOnce sync.Once
+
+	extraDetectors   []resource.Detector
+	extraDetectorsMu sync.Mutex
+
+	vendorAttributePrefix string
 )
 
+// RegisterDetector adds a detector to those used to build DefaultResource. It
+// must be called before the first call to DefaultResource, as the resource is
+// only built once.
+func RegisterDetector(d resource.Detector) {
+	extraDetectorsMu.Lock()
+	defer extraDetectorsMu.Unlock()
+	extraDetectors = append(extraDetectors, d)
+}
+
+// SetVendorAttributePrefix configures the built-in detectors used to build
+// DefaultResource to namespace the vendor-specific attributes they emit under
+// prefix, so that e.g. fly.app_name is reported as <prefix>.fly.app_name.
+// Standard semantic convention attributes are not affected. Like
+// RegisterDetector, it must be called before the first call to DefaultResource.
+func SetVendorAttributePrefix(prefix string) {
+	extraDetectorsMu.Lock()
+	defer extraDetectorsMu.Unlock()
+	vendorAttributePrefix = prefix
+}
+
+// ResetDefaultResource discards the cached DefaultResource and any detectors
+// added with RegisterDetector, so that the next call to DefaultResource builds
+// a fresh resource. It also clears any prefix set with
+// SetVendorAttributePrefix. It is intended for use in tests.
+func ResetDefaultResource() {
+	extraDetectorsMu.Lock()
+	defer extraDetectorsMu.Unlock()
+	extraDetectors = nil
+	vendorAttributePrefix = ""
+	defaultResource = nil
+	defaultResourceOnce = sync.Once{}
+}
+
 func DefaultResource() *resource.Resource {
 	defaultResourceOnce.Do(func() {
+		extraDetectorsMu.Lock()
+		detectors := []resource.Detector{
+			// We'd love to use the AWS EKS resource detector here too, but it's
+			// mostly useless: https://github.com/open-telemetry/opentelemetry-go-contrib/issues/1856
+			//
+			// The GCP detector only emits semantic convention attributes, so
+			// the vendor attribute prefix doesn't apply to it.
+			gcp.NewDetector(),
+			fly.NewDetector(fly.WithPrefix(vendorAttributePrefix)),
+			jsonfile.NewDetector(decodeResourceAttributes),
+		}
+		detectors = append(detectors, extraDetectors...)
+		extraDetectorsMu.Unlock()
+
 		var err error
 		defaultResource, err = resource.New(
 			context.Background(),
@@ -28,12 +83,7 @@ func DefaultResource() *resource.Resource {
 			resource.WithFromEnv(),
 			resource.WithTelemetrySDK(),
 			resource.WithHost(),
-			resource.WithDetectors(
-				// We'd love to use the AWS EKS resource detector here too, but it's
-				// mostly useless: https://github.com/open-telemetry/opentelemetry-go-contrib/issues/1856
-				gcp.NewDetector(),
-				fly.NewDetector(),
-			),
+			resource.WithDetectors(detectors...),
 			resource.WithAttributes(semconv.ServiceVersion(version.Version())),
 		)
 		switch {
@@ -49,3 +99,13 @@ func DefaultResource() *resource.Resource {
 
 	return defaultResource
 }
+
+// decodeResourceAttributes decodes resource attributes from a JSON object using
+// the same conversion rules as Attributes.
+func decodeResourceAttributes(b []byte) ([]attribute.KeyValue, error) {
+	var attrs Attributes
+	if err := json.Unmarshal(b, &attrs); err != nil {
+		return nil, err
+	}
+	return attrs.AsSlice(), nil
+}
@@ -0,0 +1,99 @@
+package telemetry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"github.com/replicate/go/telemetry/detectors/jsonfile"
+)
+
+func TestJSONFileDetectorUsesAttributesConversion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resource.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"service.namespace": "zoo",
+		"zoo.enclosures": 42,
+		"zoo.open": true,
+		"zoo.ignored": null
+	}`), 0o600))
+	t.Setenv(jsonfile.PathEnv, path)
+
+	res, err := jsonfile.NewDetector(decodeResourceAttributes).Detect(context.Background())
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("service.namespace", "zoo"),
+		attribute.Int64("zoo.enclosures", 42),
+		attribute.Bool("zoo.open", true),
+	}, res.Attributes())
+}
+
+type fakeDetector struct {
+	attrs []attribute.KeyValue
+}
+
+func (d fakeDetector) Detect(_ context.Context) (*resource.Resource, error) {
+	return resource.NewWithAttributes(semconv.SchemaURL, d.attrs...), nil
+}
+
+func TestRegisterDetector(t *testing.T) {
+	ResetDefaultResource()
+	t.Cleanup(ResetDefaultResource)
+
+	RegisterDetector(fakeDetector{attrs: []attribute.KeyValue{
+		attribute.String("zoo.enclosure", "reptile house"),
+	}})
+
+	v, ok := DefaultResource().Set().Value("zoo.enclosure")
+	require.True(t, ok)
+	assert.Equal(t, "reptile house", v.AsString())
+}
+
+func TestResetDefaultResource(t *testing.T) {
+	ResetDefaultResource()
+	t.Cleanup(ResetDefaultResource)
+
+	RegisterDetector(fakeDetector{attrs: []attribute.KeyValue{
+		attribute.String("zoo.enclosure", "aviary"),
+	}})
+	_, ok := DefaultResource().Set().Value("zoo.enclosure")
+	require.True(t, ok)
+
+	ResetDefaultResource()
+
+	_, ok = DefaultResource().Set().Value("zoo.enclosure")
+	assert.False(t, ok)
+}
+
+func TestSetVendorAttributePrefix(t *testing.T) {
+	ResetDefaultResource()
+	t.Cleanup(ResetDefaultResource)
+	t.Setenv("FLY_APP_NAME", "giraffe")
+	t.Setenv("FLY_REGION", "ord")
+
+	SetVendorAttributePrefix("r8")
+
+	set := DefaultResource().Set()
+
+	v, ok := set.Value("r8.fly.app_name")
+	require.True(t, ok)
+	assert.Equal(t, "giraffe", v.AsString())
+
+	v, ok = set.Value("r8.fly.region")
+	require.True(t, ok)
+	assert.Equal(t, "ord", v.AsString())
+
+	v, ok = set.Value(semconv.CloudRegionKey)
+	require.True(t, ok)
+	assert.Equal(t, "ord", v.AsString())
+
+	_, ok = set.Value("fly.app_name")
+	assert.False(t, ok)
+}
@@ -0,0 +1,17 @@
+package semconv
+
+import "go.opentelemetry.io/otel/attribute"
+
+const (
+	// ServiceKey is the name of the service which started a span, as passed to
+	// telemetry.Start.
+	ServiceKey = attribute.Key("replicate.service")
+
+	// ComponentKey is the name of the component within a service which started
+	// a span, as passed to telemetry.Start.
+	ComponentKey = attribute.Key("replicate.component")
+
+	// InstrumentationVersionKey is the version of the code which started a
+	// span.
+	InstrumentationVersionKey = attribute.Key("replicate.instrumentation.version")
+)
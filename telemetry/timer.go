@@ -1,13 +1,21 @@
 package telemetry
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	timingMeter      = Meter("go", "telemetry")
+	timingHistograms sync.Map // name -> metric.Float64Histogram
+)
+
 func Timer(span trace.Span) func(string) {
 	mark := time.Now()
 
@@ -21,3 +29,37 @@ func Timer(span trace.Span) func(string) {
 		span.SetAttributes(attribute.Int64(key, val))
 	}
 }
+
+// Time calls fn and records how long it took, both as an event named name on
+// the current span (with the duration in a duration_ms attribute) and in a
+// histogram (in seconds) of the same name, which is shared by all calls to Time
+// with that name. The histogram observation has an error attribute recording
+// whether fn returned an error. Time returns fn's error.
+func Time(ctx context.Context, name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	d := time.Since(start)
+
+	trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(
+		attribute.Float64("duration_ms", float64(d)/float64(time.Millisecond)),
+	))
+	if h, herr := timingHistogram(name); herr == nil {
+		h.Record(ctx, d.Seconds(), metric.WithAttributes(attribute.Bool("error", err != nil)))
+	} else {
+		logger.Sugar().Warnw("failed to create timing histogram", "name", name, "error", herr)
+	}
+
+	return err
+}
+
+func timingHistogram(name string) (metric.Float64Histogram, error) {
+	if h, ok := timingHistograms.Load(name); ok {
+		return h.(metric.Float64Histogram), nil
+	}
+	h, err := timingMeter.Float64Histogram(name, metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := timingHistograms.LoadOrStore(name, h)
+	return actual.(metric.Float64Histogram), nil
+}
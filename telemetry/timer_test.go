@@ -0,0 +1,66 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTime(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	origMeter := timingMeter
+	timingMeter = mp.Meter("test")
+	timingHistograms = sync.Map{}
+	t.Cleanup(func() {
+		timingMeter = origMeter
+		timingHistograms = sync.Map{}
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	ctx, span := tracer.Start(context.Background(), "my-span")
+	errBoom := errors.New("boom")
+	err := Time(ctx, "test.sleep", func() error {
+		time.Sleep(50 * time.Millisecond)
+		return errBoom
+	})
+	span.End()
+	assert.ErrorIs(t, err, errBoom)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	events := spans[0].Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "test.sleep", events[0].Name)
+	require.Len(t, events[0].Attributes, 1)
+	assert.Equal(t, "duration_ms", string(events[0].Attributes[0].Key))
+	assert.InDelta(t, 50, events[0].Attributes[0].Value.AsFloat64(), 40)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	assert.Equal(t, "test.sleep", rm.ScopeMetrics[0].Metrics[0].Name)
+
+	hist, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, hist.DataPoints, 1)
+	dp := hist.DataPoints[0]
+	assert.EqualValues(t, 1, dp.Count)
+	assert.InDelta(t, 0.05, dp.Sum, 0.04)
+	isErr, _ := dp.Attributes.Value("error")
+	assert.True(t, isErr.AsBool())
+}
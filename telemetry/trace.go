@@ -3,8 +3,12 @@ package telemetry
 import (
 	"context"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/propagation"
@@ -12,6 +16,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
+	"github.com/replicate/go/telemetry/semconv"
 	"github.com/replicate/go/version"
 )
 
@@ -23,6 +28,28 @@ func Tracer(service string, component string, opts ...trace.TracerOption) trace.
 	return otel.Tracer(name, opts...)
 }
 
+// Start starts a span using the conventionally-named tracer for the passed
+// service and component, attaching attributes which identify the service,
+// component and instrumentation version in addition to any passed attrs.
+func Start(ctx context.Context, service string, component string, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append([]attribute.KeyValue{
+		semconv.ServiceKey.String(service),
+		semconv.ComponentKey.String(component),
+		semconv.InstrumentationVersionKey.String(version.Version()),
+	}, attrs...)
+	return Tracer(service, component).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// SetDetailedAttributes sets attrs on span only if full tracing is enabled for
+// ctx (i.e. its DetailLevel is DetailLevelFull). This allows richer attributes
+// to be recorded in full trace mode without paying for them by default.
+func SetDetailedAttributes(ctx context.Context, span trace.Span, attrs ...attribute.KeyValue) {
+	if TraceOptionsFromContext(ctx).DetailLevel != DetailLevelFull {
+		return
+	}
+	span.SetAttributes(attrs...)
+}
+
 // TraceContextFromContext returns the tracecontext present in the passed
 // context, if any.
 func TraceContextFromContext(ctx context.Context) propagation.MapCarrier {
@@ -41,7 +68,24 @@ func WithTraceContext(ctx context.Context, carrier propagation.TextMapCarrier) c
 }
 
 func configureTracerProvider() {
-	tp, err := createTracerProvider(context.Background())
+	var opts []tracerProviderOption
+	// TRACE_REDACTED_ATTRIBUTES is a comma-separated list of span attribute keys
+	// whose values should never leave the process.
+	if keys := os.Getenv("TRACE_REDACTED_ATTRIBUTES"); keys != "" {
+		opts = append(opts, withRedactedAttributes(strings.Split(keys, ",")...))
+	}
+	// TRACE_MAX_ATTRIBUTE_LENGTH overrides the length at which string attribute
+	// values are truncated. A value of zero disables truncation.
+	if v := os.Getenv("TRACE_MAX_ATTRIBUTE_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			logger.Warn("ignoring invalid TRACE_MAX_ATTRIBUTE_LENGTH", zap.String("value", v))
+		} else {
+			opts = append(opts, withMaxAttributeLength(n))
+		}
+	}
+
+	tp, err := createTracerProvider(context.Background(), opts...)
 	if err != nil {
 		logger.Warn("failed to create tracer provider", zap.Error(err))
 		return
@@ -50,20 +94,63 @@ func configureTracerProvider() {
 	otel.SetTracerProvider(tp)
 }
 
-func createTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+type tracerProviderOptions struct {
+	RedactedAttributes []string
+	MaxAttributeLength int
+}
+
+type tracerProviderOption func(*tracerProviderOptions)
+
+// withRedactedAttributes configures the tracer provider to redact the values of
+// span attributes with the passed keys before export.
+func withRedactedAttributes(keys ...string) tracerProviderOption {
+	return func(opts *tracerProviderOptions) {
+		for _, k := range keys {
+			if k = strings.TrimSpace(k); k != "" {
+				opts.RedactedAttributes = append(opts.RedactedAttributes, k)
+			}
+		}
+	}
+}
+
+// withMaxAttributeLength configures the length, in bytes, beyond which string
+// span attribute values are truncated. Zero disables truncation.
+func withMaxAttributeLength(n int) tracerProviderOption {
+	return func(opts *tracerProviderOptions) {
+		opts.MaxAttributeLength = n
+	}
+}
+
+func createTracerProvider(ctx context.Context, options ...tracerProviderOption) (*sdktrace.TracerProvider, error) {
+	opts := tracerProviderOptions{
+		MaxAttributeLength: DefaultMaxAttributeLength,
+	}
+	for _, o := range options {
+		o(&opts)
+	}
+
 	exp, err := otlptrace.New(ctx, otlptracehttp.NewClient())
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize trace exporter: %w", err)
 	}
 
-	var sp sdktrace.SpanProcessor
-	sp = sdktrace.NewBatchSpanProcessor(exp)
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(newSpanProcessor(sdktrace.NewBatchSpanProcessor(exp), opts)),
+		sdktrace.WithResource(DefaultResource()),
+	), nil
+}
+
+// newSpanProcessor builds the chain of span processors which wraps the passed
+// exporting processor.
+func newSpanProcessor(export sdktrace.SpanProcessor, opts tracerProviderOptions) sdktrace.SpanProcessor {
+	sp := export
 	sp = &DroppedDataProcessor{Next: sp} // this should remain next-to-last in the chain
+	if opts.MaxAttributeLength > 0 {
+		sp = &TruncatingProcessor{Next: sp, MaxLength: opts.MaxAttributeLength}
+	}
+	if len(opts.RedactedAttributes) > 0 {
+		sp = &RedactingProcessor{Next: sp, Redact: RedactKeys(opts.RedactedAttributes...)}
+	}
 	sp = &TraceOptionsProcessor{Next: sp}
-
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithSpanProcessor(sp),
-		sdktrace.WithResource(DefaultResource()),
-	)
-	return tp, nil
+	return sp
 }
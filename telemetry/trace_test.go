@@ -7,7 +7,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/replicate/go/telemetry/semconv"
+	"github.com/replicate/go/version"
 )
 
 // TestInit is the most basic of smoke tests to ensure that we can at least
@@ -43,3 +48,45 @@ func TestTraceContextFromContext(t *testing.T) {
 
 	require.NoError(t, Shutdown(ctx))
 }
+
+func TestStart(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ctx := context.Background()
+	_, span := Start(ctx, "test", "start_test", "my-span", attribute.String("animal", "giraffe"))
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "my-span", spans[0].Name())
+	assert.Equal(t, "replicate/test/start_test", spans[0].InstrumentationScope().Name)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		semconv.ServiceKey.String("test"),
+		semconv.ComponentKey.String("start_test"),
+		semconv.InstrumentationVersionKey.String(version.Version()),
+		attribute.String("animal", "giraffe"),
+	}, spans[0].Attributes())
+}
+
+func TestSetDetailedAttributes(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	ctx := context.Background()
+	_, span := tracer.Start(ctx, "default-span")
+	SetDetailedAttributes(ctx, span, attribute.String("animal", "giraffe"))
+	span.End()
+
+	ctx = WithFullTrace(context.Background())
+	_, span = tracer.Start(ctx, "full-span")
+	SetDetailedAttributes(ctx, span, attribute.String("animal", "giraffe"))
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Empty(t, spans[0].Attributes())
+	assert.Equal(t, []attribute.KeyValue{attribute.String("animal", "giraffe")}, spans[1].Attributes())
+}
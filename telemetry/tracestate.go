@@ -2,6 +2,8 @@ package telemetry
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
@@ -35,6 +37,10 @@ type DetailLevel int
 type SampleMode int
 
 var (
+	// fullTraceUntil holds the time (in Unix nanoseconds) until which full
+	// tracing is forced on. See ForceFullTrace.
+	fullTraceUntil atomic.Int64
+
 	detailLevels = map[string]DetailLevel{
 		"":     DetailLevelDefault,
 		"full": DetailLevelFull,
@@ -60,7 +66,21 @@ type TraceOptions struct {
 
 // TraceOptionsFromContext extracts any custom trace options from the trace
 // state carried in the passed context.
+//
+// While full tracing is forced on by ForceFullTrace, options which do not
+// specify a sample mode are returned with DetailLevelFull and
+// SampleModeAlways. An explicit sample mode (such as SampleModeNever on health
+// checks) is left as it is.
 func TraceOptionsFromContext(ctx context.Context) TraceOptions {
+	to := traceOptionsFromContext(ctx)
+	if to.SampleMode == SampleModeDefault && fullTraceForced() {
+		to.DetailLevel = DetailLevelFull
+		to.SampleMode = SampleModeAlways
+	}
+	return to
+}
+
+func traceOptionsFromContext(ctx context.Context) TraceOptions {
 	// First we see if any TraceOptions are set directly in the context. If so,
 	// they override any in the SpanContext TraceState.
 	if to, ok := traceOptionsFromContextOnly(ctx); ok {
@@ -86,6 +106,20 @@ func WithFullTrace(ctx context.Context) context.Context {
 	return WithTraceOptions(ctx, to)
 }
 
+// ForceFullTrace enables full tracing (as if WithFullTrace had been called) for
+// all spans started within the next d whose context does not specify a sample
+// mode. Spans which explicitly opt out of sampling with SampleModeNever are
+// still not sampled. This is intended for briefly capturing everything during
+// an incident. Full tracing reverts automatically once d has elapsed, or
+// immediately if ForceFullTrace is called with d <= 0.
+func ForceFullTrace(d time.Duration) {
+	fullTraceUntil.Store(time.Now().Add(d).UnixNano())
+}
+
+func fullTraceForced() bool {
+	return time.Now().UnixNano() < fullTraceUntil.Load()
+}
+
 func traceOptionsFromContextOnly(ctx context.Context) (TraceOptions, bool) {
 	if v := ctx.Value(traceOptionsContextKey); v != nil {
 		if to, ok := v.(TraceOptions); ok {
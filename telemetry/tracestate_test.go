@@ -3,9 +3,15 @@ package telemetry
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/replicate/go/telemetry/semconv"
 )
 
 func TestTraceOptionsFromContextDefaults(t *testing.T) {
@@ -47,3 +53,80 @@ func TestTraceOptionsUsesSpanContextTraceOptions(t *testing.T) {
 	assert.Equal(t, DetailLevelFull, to.DetailLevel)
 	assert.Equal(t, SampleModeAlways, to.SampleMode)
 }
+
+func TestForceFullTrace(t *testing.T) {
+	t.Cleanup(func() { ForceFullTrace(0) })
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(&TraceOptionsProcessor{Next: recorder}),
+	)
+	tracer := tp.Tracer("test")
+	ctx := context.Background()
+
+	_, before := tracer.Start(ctx, "before")
+	before.End()
+
+	ForceFullTrace(time.Minute)
+
+	to := TraceOptionsFromContext(ctx)
+	assert.Equal(t, DetailLevelFull, to.DetailLevel)
+	assert.Equal(t, SampleModeAlways, to.SampleMode)
+
+	_, during := tracer.Start(ctx, "during")
+	during.End()
+
+	ForceFullTrace(0)
+
+	to = TraceOptionsFromContext(ctx)
+	assert.Equal(t, DetailLevelDefault, to.DetailLevel)
+	assert.Equal(t, SampleModeDefault, to.SampleMode)
+
+	_, after := tracer.Start(ctx, "after")
+	after.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.NotContains(t, spans[0].Attributes(), semconv.DisableSampling)
+	assert.Contains(t, spans[1].Attributes(), semconv.DisableSampling)
+	assert.NotContains(t, spans[2].Attributes(), semconv.DisableSampling)
+}
+
+func TestForceFullTraceRespectsExplicitSampleMode(t *testing.T) {
+	t.Cleanup(func() { ForceFullTrace(0) })
+
+	ForceFullTrace(time.Minute)
+
+	// An explicit sample mode set directly in the context wins...
+	ctx := WithTraceOptions(context.Background(), TraceOptions{SampleMode: SampleModeNever})
+	to := TraceOptionsFromContext(ctx)
+	assert.Equal(t, DetailLevelDefault, to.DetailLevel)
+	assert.Equal(t, SampleModeNever, to.SampleMode)
+
+	// ...as does one carried in the trace state
+	ts := trace.TraceState{}
+	ts, _ = ts.Insert("r8/sm", "never")
+	scc := makeValidSpanContextConfig()
+	scc.TraceState = ts
+	ctx = trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(scc))
+	to = TraceOptionsFromContext(ctx)
+	assert.Equal(t, DetailLevelDefault, to.DetailLevel)
+	assert.Equal(t, SampleModeNever, to.SampleMode)
+
+	// Options which don't specify a sample mode are overridden
+	ctx = WithTraceOptions(context.Background(), TraceOptions{})
+	to = TraceOptionsFromContext(ctx)
+	assert.Equal(t, DetailLevelFull, to.DetailLevel)
+	assert.Equal(t, SampleModeAlways, to.SampleMode)
+}
+
+func TestForceFullTraceReverts(t *testing.T) {
+	t.Cleanup(func() { ForceFullTrace(0) })
+
+	ForceFullTrace(10 * time.Millisecond)
+	assert.Equal(t, DetailLevelFull, TraceOptionsFromContext(context.Background()).DetailLevel)
+
+	assert.Eventually(t, func() bool {
+		return TraceOptionsFromContext(context.Background()).DetailLevel == DetailLevelDefault
+	}, 500*time.Millisecond, 5*time.Millisecond)
+}
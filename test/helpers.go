@@ -58,3 +58,24 @@ func MiniRedis(t testing.TB) (*miniredis.Miniredis, *redis.Client) {
 
 	return mr, rdb
 }
+
+// RedisOrMini returns a client for the Redis at REDIS_URL (flushed, as with
+// Redis) if it is set, and otherwise for a miniredis instance, so that tests
+// can run without a Redis server but still exercise a real one when available.
+//
+// Note that miniredis only partially emulates Redis. In particular its Lua
+// support differs in ways which affect scripts: redis.pcall does not return
+// error tables for command errors, and commands which return a null reply in
+// Redis (e.g. XREADGROUP with no entries) may return an empty array instead.
+// Tests of Lua scripts should generally use Redis.
+func RedisOrMini(ctx context.Context, t testing.TB) *redis.Client {
+	t.Helper()
+
+	if os.Getenv("REDIS_URL") != "" {
+		return Redis(ctx, t)
+	}
+
+	_, rdb := MiniRedis(t)
+	t.Cleanup(func() { _ = rdb.Close() })
+	return rdb
+}
@@ -0,0 +1,33 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisOrMini(t *testing.T) {
+	check := func(t *testing.T) {
+		ctx := Context(t)
+		rdb := RedisOrMini(ctx, t)
+
+		require.NoError(t, rdb.Set(ctx, "animal", "giraffe", 0).Err())
+		v, err := rdb.Get(ctx, "animal").Result()
+		require.NoError(t, err)
+		assert.Equal(t, "giraffe", v)
+	}
+
+	t.Run("Redis", func(t *testing.T) {
+		if os.Getenv("REDIS_URL") == "" {
+			t.Skip("REDIS_URL is not set")
+		}
+		check(t)
+	})
+
+	t.Run("MiniRedis", func(t *testing.T) {
+		t.Setenv("REDIS_URL", "")
+		check(t)
+	})
+}
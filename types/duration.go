@@ -1,6 +1,7 @@
 package types
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -8,6 +9,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 var (
@@ -17,13 +21,13 @@ var (
 	durationDay  = 24 * time.Hour
 	durationWeek = 7 * durationDay
 
-	pattYears    = `(?P<years>[\d\.]+Y)?`
-	pattMonths   = `(?P<months>[\d\.]+M)?`
-	pattWeeks    = `(?P<weeks>[\d\.]+W)?`
-	pattDays     = `(?P<days>[\d\.]+D)?`
-	pattHours    = `(?P<hours>[\d\.]+H)?`
-	pattMinutes  = `(?P<minutes>[\d\.]+M)?`
-	pattSeconds  = `(?P<seconds>[\d\.]+S)?`
+	pattYears    = `(?P<years>[\d\.,]+Y)?`
+	pattMonths   = `(?P<months>[\d\.,]+M)?`
+	pattWeeks    = `(?P<weeks>[\d\.,]+W)?`
+	pattDays     = `(?P<days>[\d\.,]+D)?`
+	pattHours    = `(?P<hours>[\d\.,]+H)?`
+	pattMinutes  = `(?P<minutes>[\d\.,]+M)?`
+	pattSeconds  = `(?P<seconds>[\d\.,]+S)?`
 	pattDuration = regexp.MustCompile(
 		`\A-?P` +
 			pattYears + pattMonths + pattWeeks + pattDays +
@@ -96,11 +100,21 @@ func (d Duration) Round(m Duration) Duration {
 	return Duration(d.Duration().Round(m.Duration()))
 }
 
-// Seconds returns the duration as a floating point number of seconds.
+// Seconds returns the duration as a floating point number of seconds. This is
+// the canonical conversion for recording durations in metrics, which should be
+// expressed in seconds.
 func (d Duration) Seconds() float64 {
 	return d.Duration().Seconds()
 }
 
+// ObserveInto records the duration in seconds in the histogram h, with the
+// given attributes. Negative durations (which usually indicate clock skew) are
+// recorded as zero, as histograms of durations are not expected to contain
+// negative values.
+func (d Duration) ObserveInto(ctx context.Context, h metric.Float64Histogram, attrs ...attribute.KeyValue) {
+	h.Record(ctx, max(d, 0).Seconds(), metric.WithAttributes(attrs...))
+}
+
 func (d Duration) String() string {
 	if int(d) == 0 {
 		return "PT0S"
@@ -181,6 +195,9 @@ func (d *Duration) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// ParseDuration parses an ISO8601 duration string. Either a period or a comma
+// may be used as the decimal separator, so "PT1.5H" and "PT1,5H" are
+// equivalent.
 func ParseDuration(s string) (Duration, error) {
 	d := time.Duration(0)
 
@@ -207,7 +224,9 @@ func ParseDuration(s string) (Duration, error) {
 			continue
 		}
 		name := names[i]
-		if f, err := strconv.ParseFloat(v[:len(v)-1], 64); err == nil {
+		// ISO8601 permits either a comma or a period as the decimal separator.
+		num := strings.Replace(v[:len(v)-1], ",", ".", 1)
+		if f, err := strconv.ParseFloat(num, 64); err == nil {
 			switch name {
 			case "years", "months":
 				if f > 0 {
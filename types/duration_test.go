@@ -1,12 +1,16 @@
 package types_test
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 
 	"github.com/replicate/go/types"
 )
@@ -54,6 +58,10 @@ func TestParseDuration(t *testing.T) {
 		{"-P0.123W", nil, -74390.4},
 		{"P0Y1W", nil, 604800},     // allow years if zero
 		{"P0.0Y0M1W", nil, 604800}, // allow months if zero
+		{"PT1,5H", nil, 5400},      // comma decimal separator
+		{"P0,5D", nil, 43200},      // comma decimal separator
+		{"PT1,5M0,25S", nil, 90.25},
+		{"-PT0,5S", nil, -0.5},
 
 		// Invalid formats
 		{"P1M2Y", types.ErrInvalidDurationString, 0},     // wrong order
@@ -109,3 +117,36 @@ func TestDurationMarshalJSON(t *testing.T) {
 
 	assert.Equal(t, `"P3DT1H14M46.789S"`, string(result))
 }
+
+func TestDurationObserveInto(t *testing.T) {
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(ctx) })
+
+	h, err := mp.Meter("test").Float64Histogram("test.duration")
+	require.NoError(t, err)
+
+	d := types.Duration(1500 * time.Millisecond)
+	d.ObserveInto(ctx, h, attribute.String("kind", "positive"))
+	types.Duration(-time.Second).ObserveInto(ctx, h, attribute.String("kind", "negative"))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+
+	hist, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, hist.DataPoints, 2)
+
+	sums := make(map[string]float64)
+	for _, dp := range hist.DataPoints {
+		kind, _ := dp.Attributes.Value("kind")
+		assert.EqualValues(t, 1, dp.Count)
+		sums[kind.AsString()] = dp.Sum
+	}
+	assert.Equal(t, d.Seconds(), sums["positive"])
+	assert.Equal(t, 0.0, sums["negative"])
+}
@@ -0,0 +1,22 @@
+package util
+
+import "fmt"
+
+// Chunk splits s into consecutive slices of length size. The final chunk will
+// be shorter than size if len(s) is not a multiple of size. The chunks share
+// s's underlying array, but are capped so that appending to one chunk cannot
+// overwrite the next.
+//
+// Chunk panics if size is not positive.
+func Chunk[T any](s []T, size int) [][]T {
+	if size <= 0 {
+		panic(fmt.Sprintf("util.Chunk: size must be > 0, got %d", size))
+	}
+
+	chunks := make([][]T, 0, (len(s)+size-1)/size)
+	for start := 0; start < len(s); start += size {
+		end := min(start+size, len(s))
+		chunks = append(chunks, s[start:end:end])
+	}
+	return chunks
+}
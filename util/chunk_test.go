@@ -0,0 +1,37 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkExactMultiple(t *testing.T) {
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5, 6}}, Chunk([]int{1, 2, 3, 4, 5, 6}, 2))
+}
+
+func TestChunkRemainder(t *testing.T) {
+	assert.Equal(t, [][]int{{1, 2, 3}, {4, 5}}, Chunk([]int{1, 2, 3, 4, 5}, 3))
+}
+
+func TestChunkSizeLargerThanInput(t *testing.T) {
+	assert.Equal(t, [][]string{{"a", "b"}}, Chunk([]string{"a", "b"}, 10))
+}
+
+func TestChunkEmpty(t *testing.T) {
+	assert.Empty(t, Chunk([]int{}, 3))
+	assert.Empty(t, Chunk[int](nil, 3))
+}
+
+func TestChunkInvalidSize(t *testing.T) {
+	assert.Panics(t, func() { Chunk([]int{1, 2, 3}, 0) })
+	assert.Panics(t, func() { Chunk([]int{1, 2, 3}, -1) })
+}
+
+func TestChunkAppendDoesNotOverwriteNextChunk(t *testing.T) {
+	chunks := Chunk([]int{1, 2, 3, 4}, 2)
+
+	_ = append(chunks[0], 99)
+
+	assert.Equal(t, []int{3, 4}, chunks[1])
+}
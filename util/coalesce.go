@@ -0,0 +1,19 @@
+package util
+
+// Coalesce returns the first of vals which is not the zero value for its type,
+// or the zero value if there is no such value.
+func Coalesce[T comparable](vals ...T) T {
+	var zero T
+	for _, v := range vals {
+		if v != zero {
+			return v
+		}
+	}
+	return zero
+}
+
+// Default returns v, unless it is the zero value for its type, in which case it
+// returns def.
+func Default[T comparable](v, def T) T {
+	return Coalesce(v, def)
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesce(t *testing.T) {
+	assert.Equal(t, 0, Coalesce[int]())
+	assert.Equal(t, 0, Coalesce(0, 0, 0))
+	assert.Equal(t, 3, Coalesce(0, 3, 5))
+	assert.Equal(t, -1, Coalesce(-1, 3))
+
+	assert.Equal(t, "", Coalesce("", ""))
+	assert.Equal(t, "giraffe", Coalesce("", "giraffe", "zebra"))
+
+	assert.Equal(t, time.Duration(0), Coalesce[time.Duration](0))
+	assert.Equal(t, time.Second, Coalesce(0, time.Second, time.Minute))
+
+	assert.False(t, Coalesce(false, false))
+	assert.True(t, Coalesce(false, true))
+
+	type point struct{ X, Y int }
+	assert.Equal(t, point{1, 0}, Coalesce(point{}, point{1, 0}, point{2, 2}))
+
+	var nilPtr *int
+	one := 1
+	assert.Equal(t, &one, Coalesce(nilPtr, &one))
+}
+
+func TestDefault(t *testing.T) {
+	assert.Equal(t, 1, Default(0, 1))
+	assert.Equal(t, 5, Default(5, 1))
+	assert.Equal(t, -5, Default(-5, 1))
+
+	assert.Equal(t, "giraffe", Default("", "giraffe"))
+	assert.Equal(t, "zebra", Default("zebra", "giraffe"))
+
+	assert.Equal(t, time.Second, Default(0, time.Second))
+	assert.Equal(t, time.Minute, Default(time.Minute, time.Second))
+}
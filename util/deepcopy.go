@@ -0,0 +1,105 @@
+// Package util contains small generic helpers which don't belong anywhere
+// else.
+package util
+
+import (
+	"reflect"
+)
+
+// DeepCopy returns a copy of src which shares no mutable state with it: maps,
+// slices, pointers and nested structs are all copied recursively, so the copy
+// can be modified without affecting the original.
+//
+// There are some limitations:
+//
+//   - Unexported struct fields are copied shallowly, as reflection cannot set
+//     them. Any maps, slices or pointers they contain will be shared.
+//   - Channels and functions are not copied: the copy refers to the same
+//     channel or function as the original.
+//   - Pointers which are shared within src remain shared within the copy, so
+//     cyclic data structures are supported.
+func DeepCopy[T any](src T) T {
+	v := reflect.ValueOf(&src).Elem()
+	dst := reflect.New(v.Type()).Elem()
+	deepCopy(dst, v, make(map[pointerKey]reflect.Value))
+	return dst.Interface().(T)
+}
+
+// pointerKey identifies a pointer already copied by DeepCopy. The type is
+// needed as a pointer to a struct and to its first field have the same address.
+type pointerKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+func deepCopy(dst, src reflect.Value, seen map[pointerKey]reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		key := pointerKey{src.Pointer(), src.Type()}
+		if p, ok := seen[key]; ok {
+			dst.Set(p)
+			return
+		}
+		p := reflect.New(src.Elem().Type())
+		seen[key] = p
+		deepCopy(p.Elem(), src.Elem(), seen)
+		dst.Set(p)
+
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		v := reflect.New(src.Elem().Type()).Elem()
+		deepCopy(v, src.Elem(), seen)
+		dst.Set(v)
+
+	case reflect.Struct:
+		// Copy the whole struct first so that unexported fields are at least
+		// copied shallowly, then replace the exported fields with deep copies.
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if !dst.Field(i).CanSet() {
+				continue
+			}
+			dst.Field(i).SetZero()
+			deepCopy(dst.Field(i), src.Field(i), seen)
+		}
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(s.Index(i), src.Index(i), seen)
+		}
+		dst.Set(s)
+
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i), seen)
+		}
+
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(iter.Key().Type()).Elem()
+			deepCopy(k, iter.Key(), seen)
+			v := reflect.New(iter.Value().Type()).Elem()
+			deepCopy(v, iter.Value(), seen)
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+
+	default:
+		// Basic types, channels and functions.
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type animal struct {
+	Name    string
+	Tags    []string
+	Attrs   map[string][]int
+	Parent  *animal
+	Friends []*animal
+	Extra   any
+
+	secret []string
+}
+
+func TestDeepCopyNestedMapsAndSlices(t *testing.T) {
+	src := map[string][]map[string]int{
+		"giraffes": {{"height": 5}, {"height": 6}},
+	}
+
+	dst := DeepCopy(src)
+	assert.Equal(t, src, dst)
+
+	dst["giraffes"][0]["height"] = 100
+	dst["giraffes"] = append(dst["giraffes"], map[string]int{"height": 7})
+	dst["zebras"] = nil
+
+	assert.Equal(t, map[string][]map[string]int{
+		"giraffes": {{"height": 5}, {"height": 6}},
+	}, src)
+}
+
+func TestDeepCopyStruct(t *testing.T) {
+	parent := &animal{Name: "mother"}
+	src := animal{
+		Name:    "giraffe",
+		Tags:    []string{"tall", "spotty"},
+		Attrs:   map[string][]int{"legs": {4}},
+		Parent:  parent,
+		Friends: []*animal{{Name: "zebra"}},
+		Extra:   map[string]string{"habitat": "savannah"},
+		secret:  []string{"hidden"},
+	}
+
+	dst := DeepCopy(src)
+	assert.Equal(t, src, dst)
+
+	dst.Tags[0] = "short"
+	dst.Attrs["legs"][0] = 3
+	dst.Parent.Name = "father"
+	dst.Friends[0].Name = "lion"
+	dst.Extra.(map[string]string)["habitat"] = "zoo"
+
+	assert.Equal(t, []string{"tall", "spotty"}, src.Tags)
+	assert.Equal(t, []int{4}, src.Attrs["legs"])
+	assert.Equal(t, "mother", src.Parent.Name)
+	assert.Equal(t, "zebra", src.Friends[0].Name)
+	assert.Equal(t, "savannah", src.Extra.(map[string]string)["habitat"])
+
+	// Unexported fields are copied shallowly.
+	dst.secret[0] = "revealed"
+	assert.Equal(t, "revealed", src.secret[0])
+}
+
+func TestDeepCopyPointer(t *testing.T) {
+	src := &animal{Name: "giraffe", Tags: []string{"tall"}}
+
+	dst := DeepCopy(src)
+	assert.NotSame(t, src, dst)
+	assert.Equal(t, src, dst)
+
+	dst.Tags[0] = "short"
+	assert.Equal(t, "tall", src.Tags[0])
+}
+
+func TestDeepCopyCycle(t *testing.T) {
+	src := &animal{Name: "ouroboros"}
+	src.Parent = src
+
+	dst := DeepCopy(src)
+	assert.NotSame(t, src, dst)
+	assert.Same(t, dst, dst.Parent)
+}
+
+func TestDeepCopyNil(t *testing.T) {
+	var m map[string]int
+	assert.Nil(t, DeepCopy(m))
+
+	var s []int
+	assert.Nil(t, DeepCopy(s))
+
+	var p *animal
+	assert.Nil(t, DeepCopy(p))
+}
+
+func TestDeepCopySharesChannelsAndFuncs(t *testing.T) {
+	type withChan struct {
+		C chan int
+	}
+	src := withChan{C: make(chan int)}
+
+	dst := DeepCopy(src)
+	assert.Equal(t, src.C, dst.C)
+}
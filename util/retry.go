@@ -0,0 +1,62 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Retry calls fn up to attempts times, until it returns a nil error, and
+// returns its result. Between attempts it waits for the duration returned by
+// backoff, which is passed the number of attempts made so far (starting at 1).
+// A nil backoff retries immediately.
+//
+// If all attempts fail, Retry returns the error from the last attempt. If ctx is
+// canceled while waiting between attempts, Retry returns immediately with an
+// error wrapping both the context's error and the last error from fn.
+func Retry[T any](ctx context.Context, attempts int, backoff func(attempt int) time.Duration, fn func() (T, error)) (T, error) {
+	var (
+		result T
+		err    error
+	)
+
+	for attempt := 1; ; attempt++ {
+		result, err = fn()
+		if err == nil || attempt >= attempts {
+			return result, err
+		}
+
+		var wait time.Duration
+		if backoff != nil {
+			wait = backoff(attempt)
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			var zero T
+			return zero, fmt.Errorf("%w (last error: %w)", ctx.Err(), err)
+		case <-t.C:
+		}
+	}
+}
+
+// ExponentialBackoff returns a backoff function for use with Retry. The wait
+// before retrying after the nth attempt is chosen uniformly at random from
+// [0, base*2^(n-1)), capped at maxWait ("full jitter"), which prevents many
+// clients retrying at the same time from doing so in lockstep.
+func ExponentialBackoff(base, maxWait time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		ceiling := maxWait
+		// Avoid overflow: once the shift would exceed maxWait, just use maxWait.
+		if attempt-1 < 63 && base <= maxWait>>(attempt-1) {
+			ceiling = base << (attempt - 1)
+		}
+		if ceiling <= 0 {
+			return 0
+		}
+		return time.Duration(rand.Int64N(int64(ceiling)))
+	}
+}
@@ -0,0 +1,83 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errFlaky = errors.New("flaky")
+
+func TestRetrySucceedsAfterFailures(t *testing.T) {
+	calls := 0
+	result, err := Retry(context.Background(), 5, nil, func() (string, error) {
+		calls++
+		if calls < 3 {
+			return "", errFlaky
+		}
+		return "giraffe", nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "giraffe", result)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryExhaustsAttempts(t *testing.T) {
+	calls := 0
+	var backoffs []int
+	_, err := Retry(context.Background(), 3, func(attempt int) time.Duration {
+		backoffs = append(backoffs, attempt)
+		return time.Millisecond
+	}, func() (int, error) {
+		calls++
+		return 0, errFlaky
+	})
+
+	assert.ErrorIs(t, err, errFlaky)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{1, 2}, backoffs)
+}
+
+func TestRetryAtLeastOneAttempt(t *testing.T) {
+	calls := 0
+	_, err := Retry(context.Background(), 0, nil, func() (int, error) {
+		calls++
+		return 0, errFlaky
+	})
+
+	assert.ErrorIs(t, err, errFlaky)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	_, err := Retry(ctx, 5, func(int) time.Duration { return time.Hour }, func() (int, error) {
+		calls++
+		cancel()
+		return 0, errFlaky
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, errFlaky)
+	assert.Equal(t, 1, calls)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 100*time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		assert.Less(t, backoff(1), 10*time.Millisecond)
+		assert.Less(t, backoff(2), 20*time.Millisecond)
+		assert.Less(t, backoff(3), 40*time.Millisecond)
+		assert.Less(t, backoff(5), 100*time.Millisecond)
+		assert.Less(t, backoff(100), 100*time.Millisecond)
+		assert.GreaterOrEqual(t, backoff(100), time.Duration(0))
+	}
+}